@@ -0,0 +1,225 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// commandsUsage describes the commands, printed by "gemails help" and for an unknown command
+const commandsUsage = `Usage: gemails [command] [flags]
+
+Commands:
+  report   Collect the emails of GitHub accounts and check the expiry of their domains (default)
+  collect  Only collect the emails and domains, without WHOIS checks
+  whois    Check the expiry of domains given as arguments, in a file or on stdin, without scanning GitHub
+
+Run "gemails <command> -h" for the flags of a command.
+`
+
+func main() {
+	// Without a command, the flags are those of report, so existing invocations keep working
+	name, args := "report", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	switch name {
+	case "report":
+		runScan(name, args, true)
+	case "collect":
+		runScan(name, args, false)
+	case "whois":
+		runWhois(args)
+	case "help":
+		fmt.Print(commandsUsage)
+	default:
+		fmt.Fprint(os.Stderr, commandsUsage)
+		fatalf("Unknown command %q", name)
+	}
+}
+
+// whoisOptions are the flags shared by the commands that run WHOIS expiry checks
+type whoisOptions struct {
+	cachePath      *string
+	cacheTTL       *time.Duration
+	servers        mapFlag
+	concurrency    *int
+	noRDAP         *bool
+	verbose        *bool
+	format         *string
+	output         *string
+	expiryDays     *int
+	expandTLDs     listFlag
+	failIfExpiring *bool
+	webhook        *string
+}
+
+// addWhoisFlags defines the WHOIS flags on fs
+func addWhoisFlags(fs *flag.FlagSet) *whoisOptions {
+	o := &whoisOptions{servers: mapFlag{}}
+	o.cachePath = fs.String("whois-cache", defaultWhoisCachePath(), "File caching WHOIS results between runs (empty disables caching)")
+	o.cacheTTL = fs.Duration("whois-cache-ttl", 7*24*time.Hour, "How long cached WHOIS results stay fresh")
+	fs.Var(o.servers, "whois-server", "WHOIS server to use for a TLD, e.g. de=whois.denic.de (repeatable or comma-separated)")
+	o.concurrency = fs.Int("whois-concurrency", 2, "Number of WHOIS lookups to run in parallel")
+	o.noRDAP = fs.Bool("no-rdap", false, "Only use WHOIS for domain lookups instead of trying RDAP first")
+	o.verbose = fs.Bool("whois-verbose", false, "Also print the registrar, creation date and name servers of each domain")
+	fs.Var(&o.expandTLDs, "expand-tlds", "Also check each corporate domain's name under these TLDs, e.g. com,net,org,io, reporting unregistered siblings (repeatable or comma-separated)")
+	o.format = fs.String("whois-format", "lines", "How WHOIS results are printed: lines or table")
+	o.output = fs.String("whois-output", "", "Also write the WHOIS expiry results as a JSON array to this file")
+	o.expiryDays = fs.Int("expiry-days", 30, "Warn about domains expiring within this many days")
+	o.failIfExpiring = fs.Bool("fail-if-expiring", false, fmt.Sprintf("Exit with status %d when any checked domain is within the -expiry-days threshold", exitExpiring))
+	o.webhook = fs.String("webhook", "", "Post the domains within the -expiry-days threshold as JSON to this URL, e.g. a Slack incoming webhook, when there are any")
+	return o
+}
+
+// validate exits on invalid WHOIS flag values
+func (o *whoisOptions) validate() {
+	if *o.format != "lines" && *o.format != "table" {
+		fatalf("Unknown WHOIS format %q (expected lines or table)", *o.format)
+	}
+	validateWebhook(*o.webhook)
+}
+
+// run checks the expiry of domains and of their -expand-tlds siblings, recording the
+// results in summary and, when db is non-nil, in the database
+func (o *whoisOptions) run(domains map[string]bool, summary *runSummary, db *database) {
+	var cache *whoisCache
+	if *o.cachePath != "" {
+		cache = loadWhoisCache(*o.cachePath, *o.cacheTTL)
+	}
+	checker := &whoisChecker{
+		cache:       cache,
+		expiryDays:  *o.expiryDays,
+		concurrency: *o.concurrency,
+		verbose:     *o.verbose,
+		noRDAP:      *o.noRDAP,
+		format:      *o.format,
+		servers:     o.servers,
+	}
+	expiries, expiring := checker.checkDomainsExpiry(domains)
+	summary.Expiring = expiring
+	summary.Unregistered = countAvailable(expiries)
+	if *o.output != "" {
+		if err := checker.saveReport(expiries, *o.output); err != nil {
+			failf("Error writing WHOIS output: %v", err)
+		}
+	}
+	if db != nil {
+		if err := db.saveExpiries(checker, expiries); err != nil {
+			failf("Error writing to database: %v", err)
+		}
+	}
+	if *o.webhook != "" {
+		if err := checker.notifyExpiring(expiries, *o.webhook); err != nil {
+			failf("Error notifying the webhook: %v", err)
+		}
+	}
+	summary.checkedExpiry = true
+
+	// Unregistered variants of a brand's domain under other TLDs are open to squatting
+	if len(o.expandTLDs) > 0 {
+		siblings := siblingDomains(domains, o.expandTLDs)
+		infof("\nChecking %d sibling domains under %s", len(siblings), strings.Join(o.expandTLDs, ", "))
+		siblingExpiries, _ := checker.checkDomainsExpiry(siblings)
+		summary.AvailableSiblings = countAvailable(siblingExpiries)
+		summary.checkedSiblings = true
+	}
+}
+
+// parseDomainList returns the set of domains in list. Only the first field of an entry is
+// used and an email address is reduced to its domain, so that the outputs of the other
+// commands, e.g. -with-counts text files, can be piped in as they are.
+func parseDomainList(list []string) map[string]bool {
+	domains := make(map[string]bool)
+	for _, entry := range list {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		domain := fields[0]
+		if at := strings.LastIndex(domain, "@"); at >= 0 {
+			domain = domain[at+1:]
+		}
+		if domain = strings.ToLower(strings.TrimSuffix(domain, ".")); domain != "" {
+			domains[domain] = true
+		}
+	}
+	return domains
+}
+
+// runWhois runs the whois command: it checks the expiry of the domains given as arguments
+// and in the -f file or, when there are none, read from stdin
+func runWhois(args []string) {
+	fs := flag.NewFlagSet("whois", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gemails whois [flags] [<domain>...]\n(reads one domain per line from stdin without domains or -f)\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	whois := addWhoisFlags(fs)
+	domainsFile := fs.String("f", "", "File with one domain per line to check, e.g. a -domains-out file (\"-\" for stdin)")
+	proxyURL := fs.String("proxy", "", "Proxy for RDAP and WHOIS traffic, e.g. socks5://127.0.0.1:1080 (defaults to HTTP(S)_PROXY/ALL_PROXY)")
+	whoisProxy := fs.String("proxy-whois", "", "SOCKS proxy for WHOIS lookups only, e.g. socks5://127.0.0.1:1080 (defaults to -proxy)")
+	strict := fs.Bool("strict", false, fmt.Sprintf("Exit with status %d when any domain lookup failed, instead of only warning", exitIncomplete))
+	fs.BoolVar(&quiet, "quiet", false, "Only print warnings, errors and results, not progress lines")
+	noColor := fs.Bool("no-color", false, "Disable colored output, which is also off when not writing to a terminal or when NO_COLOR is set")
+	configPath := fs.String("config", "", "YAML or TOML file of default flag values (defaults to gemails.yaml or gemails.toml in the working directory)")
+	fs.Parse(args)
+
+	// Registered first so that it runs after every other deferred cleanup
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	// The config file is shared with the scan commands, whose keys do not apply here
+	if path := findConfig(*configPath); path != "" {
+		if err := applyConfig(fs, path, true); err != nil {
+			fatalf("Error loading config file: %v", err)
+		}
+	}
+	configureColor(*noColor)
+	whois.validate()
+	validateWhoisProxy(*whoisProxy)
+
+	list := fs.Args()
+	switch {
+	case *domainsFile != "" && *domainsFile != "-":
+		names, err := readList(*domainsFile)
+		if err != nil {
+			fatalf("Error reading domains file: %v", err)
+		}
+		list = append(list, names...)
+	case *domainsFile == "-" || len(list) == 0:
+		// Waiting for a domain list typed at the terminal would look like a hang
+		if *domainsFile == "" && isTerminal(os.Stdin) {
+			fs.Usage()
+			fatalf("No domains to check: give them as arguments, with -f or on stdin")
+		}
+		names, err := readListFrom(os.Stdin)
+		if err != nil {
+			fatalf("Error reading domains from stdin: %v", err)
+		}
+		list = append(list, names...)
+	}
+	domains := parseDomainList(list)
+	if len(domains) == 0 {
+		fatalf("No domains to check")
+	}
+	configureWhoisProxy(*proxyURL, *whoisProxy)
+
+	summary := runSummary{Domains: len(domains)}
+	whois.run(domains, &summary, nil)
+	summary.Errors = failures()
+	summary.print(statusOut)
+	switch {
+	case *strict && len(summary.Errors) > 0:
+		exitCode = exitIncomplete
+	case *whois.failIfExpiring && summary.Expiring > 0:
+		exitCode = exitExpiring
+	}
+}
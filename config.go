@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultConfigFiles are looked up in the working directory when -config is not given
+var defaultConfigFiles = []string{"gemails.yaml", "gemails.yml", "gemails.toml"}
+
+// configAliases maps readable config keys to the short flag they set
+var configAliases = map[string]string{
+	"token":       "t",
+	"token-file":  "t-file",
+	"users":       "u",
+	"user":        "u",
+	"output":      "o",
+	"repo":        "r",
+	"concurrency": "c",
+	"verbose":     "v",
+}
+
+// configEntry is one key of a config file and its values; lists have several
+type configEntry struct {
+	key    string
+	values []string
+	line   int
+}
+
+// findConfig returns path when set, otherwise the first default config file present, or ""
+func findConfig(path string) string {
+	if path != "" {
+		return path
+	}
+	for _, name := range defaultConfigFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// applyConfig sets every flag of fs that was not given on the command line from the config
+// file, so command-line flags always override file values. With skipUnknown, keys that are
+// not flags of fs are ignored, for commands that share the file but only some of its flags.
+func applyConfig(fs *flag.FlagSet, path string, skipUnknown bool) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	entries, err := parseConfig(string(data), filepath.Ext(path) == ".toml")
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	set := setFlags(fs)
+	for _, entry := range entries {
+		name := entry.key
+		if alias, ok := configAliases[name]; ok {
+			name = alias
+		}
+		if fs.Lookup(name) == nil && skipUnknown {
+			continue
+		}
+		if name == "config" || fs.Lookup(name) == nil {
+			return fmt.Errorf("%s:%d: unknown option %q", path, entry.line, entry.key)
+		}
+		if set[name] {
+			continue
+		}
+		// Lists are joined, which both comma-separated and repeatable flags accept
+		if err := fs.Set(name, strings.Join(entry.values, ",")); err != nil {
+			return fmt.Errorf("%s:%d: invalid value for %q: %w", path, entry.line, entry.key, err)
+		}
+	}
+	return nil
+}
+
+// setFlags returns the names of the flags of fs that have been set. Called before
+// applyConfig, these are the flags given on the command line: applyConfig sets the others
+// through fs.Set, after which fs.Visit no longer tells them apart.
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
+
+// yieldsTo reports whether flag name, which conflicts with flag other, takes its value from
+// the config file while other was given on the command line, so that name gives way
+func yieldsTo(commandLine map[string]bool, name, other string) bool {
+	return !commandLine[name] && commandLine[other]
+}
+
+// parseConfig parses a flat YAML ("key: value") or TOML ("key = value") file.
+// Values may be quoted, and lists are written inline as [a, b] or, in YAML, as "- item" lines.
+func parseConfig(data string, toml bool) ([]configEntry, error) {
+	separator := ":"
+	if toml {
+		separator = "="
+	}
+
+	var entries []configEntry
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" || line == "---" {
+			continue
+		}
+
+		// YAML block list items belong to the preceding key without a value
+		if !toml && strings.HasPrefix(line, "- ") {
+			if len(entries) == 0 {
+				return nil, fmt.Errorf("line %d: list item without a key", i+1)
+			}
+			last := &entries[len(entries)-1]
+			last.values = append(last.values, unquote(strings.TrimSpace(line[2:])))
+			continue
+		}
+
+		parts := strings.SplitN(line, separator, 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("line %d: expected key%s value", i+1, separator)
+		}
+		entry := configEntry{key: strings.TrimSpace(parts[0]), line: i + 1}
+		value := strings.TrimSpace(parts[1])
+		if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = unquote(strings.TrimSpace(item)); item != "" {
+					entry.values = append(entry.values, item)
+				}
+			}
+		} else if value != "" {
+			entry.values = []string{unquote(value)}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// stripComment removes a # comment that is not inside a quoted value
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote strips matching single or double quotes around a value
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
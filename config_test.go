@@ -0,0 +1,195 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		toml bool
+		want []configEntry
+	}{
+		{
+			name: "yaml scalars",
+			data: "---\nusers: octocat\nquiet: true\nexpiry-days: 14\n",
+			want: []configEntry{
+				{key: "users", values: []string{"octocat"}, line: 2},
+				{key: "quiet", values: []string{"true"}, line: 3},
+				{key: "expiry-days", values: []string{"14"}, line: 4},
+			},
+		},
+		{
+			name: "quotes and comments",
+			data: "# gemails defaults\noutput: \"emails # all.txt\" # kept in quotes\nproxy: 'socks5://127.0.0.1:1080'\n\n",
+			want: []configEntry{
+				{key: "output", values: []string{"emails # all.txt"}, line: 2},
+				{key: "proxy", values: []string{"socks5://127.0.0.1:1080"}, line: 3},
+			},
+		},
+		{
+			name: "yaml lists",
+			data: "users: [octocat, \"hubot\", ]\nexpand-tlds:\n  - com\n  - 'io'\n",
+			want: []configEntry{
+				{key: "users", values: []string{"octocat", "hubot"}, line: 1},
+				{key: "expand-tlds", values: []string{"com", "io"}, line: 2},
+			},
+		},
+		{
+			name: "toml",
+			data: "users = [\"octocat\", \"hubot\"]\nno-whois = true\ntoken = \"ghp_x=y\" # a comment\n",
+			toml: true,
+			want: []configEntry{
+				{key: "users", values: []string{"octocat", "hubot"}, line: 1},
+				{key: "no-whois", values: []string{"true"}, line: 2},
+				{key: "token", values: []string{"ghp_x=y"}, line: 3},
+			},
+		},
+	}
+	for _, test := range tests {
+		got, err := parseConfig(test.data, test.toml)
+		if err != nil {
+			t.Errorf("%s: parseConfig: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: parseConfig = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		toml bool
+		want string
+	}{
+		{"- com\n", false, "line 1: list item without a key"},
+		{"users: octocat\nquiet\n", false, "line 2: expected key: value"},
+		{": value\n", false, "line 1: expected key: value"},
+		{"users: octocat\n", true, "line 1: expected key= value"},
+	}
+	for _, test := range tests {
+		if _, err := parseConfig(test.data, test.toml); err == nil || err.Error() != test.want {
+			t.Errorf("parseConfig(%q): err = %v, want %q", test.data, err, test.want)
+		}
+	}
+}
+
+// configFlags returns a flag set with flags of each kind that config files set
+func configFlags() (*flag.FlagSet, *string, *bool, *listFlag) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	output := fs.String("o", "", "")
+	quiet := fs.Bool("quiet", false, "")
+	users := &listFlag{}
+	fs.Var(users, "u", "")
+	fs.String("config", "", "")
+	return fs, output, quiet, users
+}
+
+// writeConfig writes data to a config file named name in a temporary directory
+func writeConfig(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyConfig(t *testing.T) {
+	path := writeConfig(t, "gemails.yaml", "output: emails.txt\nquiet: true\nusers:\n  - octocat\n  - hubot\n")
+	fs, output, quiet, users := configFlags()
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(fs, path, false); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+	if *output != "emails.txt" {
+		t.Errorf("o = %q, want %q", *output, "emails.txt")
+	}
+	if !*quiet {
+		t.Error("quiet = false, want true")
+	}
+	if want := (listFlag{"octocat", "hubot"}); !reflect.DeepEqual(*users, want) {
+		t.Errorf("u = %v, want %v", *users, want)
+	}
+}
+
+func TestApplyConfigCommandLineOverrides(t *testing.T) {
+	path := writeConfig(t, "gemails.toml", "output = \"emails.txt\"\nquiet = true\nusers = [\"octocat\"]\n")
+	fs, output, quiet, users := configFlags()
+	if err := fs.Parse([]string{"-o", "other.txt", "-quiet=false"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(fs, path, false); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+	if *output != "other.txt" {
+		t.Errorf("o = %q, want the command-line %q", *output, "other.txt")
+	}
+	if *quiet {
+		t.Error("quiet = true, want the command-line false")
+	}
+	if want := (listFlag{"octocat"}); !reflect.DeepEqual(*users, want) {
+		t.Errorf("u = %v, want %v", *users, want)
+	}
+}
+
+func TestApplyConfigErrors(t *testing.T) {
+	tests := []struct {
+		data        string
+		skipUnknown bool
+		want        string
+	}{
+		{"whois-output: report.json\n", false, `:1: unknown option "whois-output"`},
+		{"config: other.yaml\n", false, `:1: unknown option "config"`},
+		{"config: other.yaml\n", true, `:1: unknown option "config"`},
+		{"output: emails.txt\nquiet: maybe\n", false, `:2: invalid value for "quiet"`},
+		{"output emails.txt\n", false, "line 1: expected key: value"},
+	}
+	for _, test := range tests {
+		path := writeConfig(t, "gemails.yaml", test.data)
+		fs, _, _, _ := configFlags()
+		fs.Parse(nil)
+		if err := applyConfig(fs, path, test.skipUnknown); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("applyConfig(%q): err = %v, want it to contain %q", test.data, err, test.want)
+		}
+	}
+}
+
+func TestApplyConfigSkipUnknown(t *testing.T) {
+	path := writeConfig(t, "gemails.yaml", "output: emails.txt\nwhois-output: report.json\n")
+	fs, output, _, _ := configFlags()
+	fs.Parse(nil)
+	if err := applyConfig(fs, path, true); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+	if *output != "emails.txt" {
+		t.Errorf("o = %q, want %q", *output, "emails.txt")
+	}
+}
+
+func TestSetFlagsTellsCommandLineFromConfig(t *testing.T) {
+	path := writeConfig(t, "gemails.yaml", "output: emails.txt\nquiet: true\n")
+	fs, _, _, _ := configFlags()
+	fs.Parse([]string{"-u", "octocat"})
+	commandLine := setFlags(fs)
+	if err := applyConfig(fs, path, false); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+	if want := map[string]bool{"u": true}; !reflect.DeepEqual(commandLine, want) {
+		t.Errorf("setFlags before applyConfig = %v, want %v", commandLine, want)
+	}
+	if !yieldsTo(commandLine, "o", "u") || yieldsTo(commandLine, "u", "o") {
+		t.Error("yieldsTo does not let the config file's o give way to the command-line u")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// quiet suppresses info-level progress lines; warnings and errors are always shown
+var quiet bool
+
+var (
+	warnColor  = color.New(color.FgYellow)
+	errorColor = color.New(color.FgRed)
+)
+
+// configureColor disables colors when disable is set, and otherwise decides for stdout and
+// stderr separately, as either can be redirected. The color package already turns them off
+// for stdout when it is not a terminal, TERM is dumb or NO_COLOR is set.
+func configureColor(disable bool) {
+	if disable {
+		color.NoColor = true
+	}
+	// Warnings and errors are logged to stderr
+	if disable || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || !isTerminal(os.Stderr) {
+		warnColor.DisableColor()
+		errorColor.DisableColor()
+	} else {
+		warnColor.EnableColor()
+		errorColor.EnableColor()
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or a pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// infof prints a progress line to statusOut unless -quiet is set
+func infof(format string, args ...interface{}) {
+	if !quiet {
+		fmt.Fprintf(statusOut, format+"\n", args...)
+	}
+}
+
+// warnf logs a yellow warning to stderr
+func warnf(format string, args ...interface{}) {
+	log.Print(warnColor.Sprintf(format, args...))
+}
+
+// errorf logs a red error to stderr
+func errorf(format string, args ...interface{}) {
+	log.Print(errorColor.Sprintf(format, args...))
+}
+
+// runErrors holds the non-fatal failures of the run, listed at its end
+var runErrors struct {
+	sync.Mutex
+	messages []string
+}
+
+// failf logs a red error like errorf and records it as a failure that left the run incomplete
+func failf(format string, args ...interface{}) {
+	errorf(format, args...)
+	recordFailure(fmt.Sprintf(format, args...))
+}
+
+// recordFailure records an already logged failure that left the run incomplete
+func recordFailure(msg string) {
+	runErrors.Lock()
+	defer runErrors.Unlock()
+	runErrors.messages = append(runErrors.messages, msg)
+}
+
+// failures returns the failures recorded so far
+func failures() []string {
+	runErrors.Lock()
+	defer runErrors.Unlock()
+	return append([]string(nil), runErrors.messages...)
+}
+
+// fatalf logs a red error to stderr and exits
+func fatalf(format string, args ...interface{}) {
+	errorf(format, args...)
+	os.Exit(1)
+}
+
+// libraryLog routes the library's log lines to errorf or warnf, depending on whether
+// they report an error
+type libraryLog struct{}
+
+func (libraryLog) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	if strings.HasPrefix(msg, "Error") {
+		errorf("%s", msg)
+	} else {
+		warnf("%s", msg)
+	}
+	return len(p), nil
+}
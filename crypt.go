@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedMagic starts every file written with -encrypt
+const encryptedMagic = "GEMAILS-ENC1"
+
+// scrypt work factors for deriving the AES-256 key from a passphrase, the interactive-use
+// recommendation of the scrypt paper
+const (
+	scryptN    = 1 << 15
+	scryptR    = 8
+	scryptP    = 1
+	saltSize   = 16
+	aesKeySize = 32
+)
+
+// passphraseEnv holds the -encrypt and -decrypt passphrase when -passphrase is not given
+const passphraseEnv = "GEMAILS_PASSPHRASE"
+
+// outputPassphrase, when set by -encrypt, makes openOutput encrypt every output file
+var outputPassphrase string
+
+// encryptData seals plaintext with AES-GCM under a key derived from passphrase with scrypt.
+// The result is the magic, the random salt and nonce, then the ciphertext; the magic, salt
+// and nonce are authenticated along with it.
+func encryptData(plaintext []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	header := append(append([]byte(encryptedMagic), salt...), nonce...)
+	return gcm.Seal(header, nonce, plaintext, header), nil
+}
+
+// decryptData opens data sealed by encryptData
+func decryptData(data []byte, passphrase string) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(encryptedMagic)) {
+		return nil, errors.New("not a file encrypted by gemails")
+	}
+	if len(data) < len(encryptedMagic)+saltSize {
+		return nil, errors.New("truncated encrypted file")
+	}
+	salt := data[len(encryptedMagic) : len(encryptedMagic)+saltSize]
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	headerSize := len(encryptedMagic) + saltSize + gcm.NonceSize()
+	if len(data) < headerSize {
+		return nil, errors.New("truncated encrypted file")
+	}
+
+	header := data[:headerSize]
+	plaintext, err := gcm.Open(nil, header[len(encryptedMagic)+saltSize:], data[headerSize:], header)
+	if err != nil {
+		return nil, errors.New("wrong passphrase or corrupted file")
+	}
+	return plaintext, nil
+}
+
+// newGCM derives the key for passphrase and salt and returns its AES-GCM cipher
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, aesKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptedOutput buffers what is written to an output file and writes it encrypted on Close,
+// as AES-GCM authenticates the whole content at once
+type encryptedOutput struct {
+	bytes.Buffer
+	file       io.WriteCloser
+	passphrase string
+}
+
+func (e *encryptedOutput) Close() error {
+	data, err := encryptData(e.Bytes(), e.passphrase)
+	if err != nil {
+		e.file.Close()
+		return fmt.Errorf("error encrypting output: %w", err)
+	}
+	if _, err := e.file.Write(data); err != nil {
+		e.file.Close()
+		return err
+	}
+	return e.file.Close()
+}
+
+// decryptFile writes the decrypted content of the file at path to w
+func decryptFile(path, passphrase string, w io.Writer) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	plaintext, err := decryptData(data, passphrase)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	_, err = w.Write(plaintext)
+	return err
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDataRoundTrip(t *testing.T) {
+	plaintext := []byte("dev@example.com\nops@example.org\n")
+	sealed, err := encryptData(plaintext, "secret")
+	if err != nil {
+		t.Fatalf("encryptData: %v", err)
+	}
+	if !bytes.HasPrefix(sealed, []byte(encryptedMagic)) {
+		t.Errorf("sealed data does not start with %q", encryptedMagic)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Error("sealed data contains the plaintext")
+	}
+
+	opened, err := decryptData(sealed, "secret")
+	if err != nil {
+		t.Fatalf("decryptData: %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Errorf("decryptData = %q, want %q", opened, plaintext)
+	}
+
+	// The random salt and nonce make each encryption of the same content differ
+	again, err := encryptData(plaintext, "secret")
+	if err != nil {
+		t.Fatalf("encryptData: %v", err)
+	}
+	if bytes.Equal(again, sealed) {
+		t.Error("encrypting twice gave the same output")
+	}
+}
+
+func TestEncryptedOutputDecryptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "emails.txt")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := &encryptedOutput{file: file, passphrase: "secret"}
+	output.WriteString("dev@example.com\n")
+	if err := output.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	var decrypted bytes.Buffer
+	if err := decryptFile(path, "secret", &decrypted); err != nil {
+		t.Fatalf("decryptFile: %v", err)
+	}
+	if got := decrypted.String(); got != "dev@example.com\n" {
+		t.Errorf("decryptFile wrote %q, want %q", got, "dev@example.com\n")
+	}
+}
+
+func TestDecryptDataWrongPassphrase(t *testing.T) {
+	sealed, err := encryptData([]byte("dev@example.com\n"), "secret")
+	if err != nil {
+		t.Fatalf("encryptData: %v", err)
+	}
+	if _, err := decryptData(sealed, "guess"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("decryptData with the wrong passphrase: err = %v, want a wrong passphrase error", err)
+	}
+}
+
+func TestDecryptDataTamperedHeader(t *testing.T) {
+	sealed, err := encryptData([]byte("dev@example.com\n"), "secret")
+	if err != nil {
+		t.Fatalf("encryptData: %v", err)
+	}
+	saltStart := len(encryptedMagic)
+	nonceStart := saltStart + saltSize
+	tests := map[string]struct {
+		offset int
+		want   string
+	}{
+		"magic":      {0, "not a file encrypted by gemails"},
+		"salt":       {saltStart, "wrong passphrase or corrupted file"},
+		"nonce":      {nonceStart, "wrong passphrase or corrupted file"},
+		"ciphertext": {len(sealed) - 1, "wrong passphrase or corrupted file"},
+	}
+	for name, test := range tests {
+		tampered := append([]byte(nil), sealed...)
+		tampered[test.offset] ^= 0x01
+		if _, err := decryptData(tampered, "secret"); err == nil || err.Error() != test.want {
+			t.Errorf("decryptData with a tampered %s: err = %v, want %q", name, err, test.want)
+		}
+	}
+}
+
+func TestDecryptDataTruncated(t *testing.T) {
+	sealed, err := encryptData([]byte("dev@example.com\n"), "secret")
+	if err != nil {
+		t.Fatalf("encryptData: %v", err)
+	}
+	tests := map[string]struct {
+		size int
+		want string
+	}{
+		"empty":       {0, "not a file encrypted by gemails"},
+		"magic only":  {len(encryptedMagic), "truncated encrypted file"},
+		"no nonce":    {len(encryptedMagic) + saltSize + 4, "truncated encrypted file"},
+		"header only": {len(encryptedMagic) + saltSize + 12, "wrong passphrase or corrupted file"},
+		"short":       {len(sealed) - 1, "wrong passphrase or corrupted file"},
+	}
+	for name, test := range tests {
+		if _, err := decryptData(sealed[:test.size], "secret"); err == nil || err.Error() != test.want {
+			t.Errorf("decryptData of %s input: err = %v, want %q", name, err, test.want)
+		}
+	}
+}
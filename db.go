@@ -0,0 +1,153 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mux0x/gemails/gemails"
+	// Pure-Go SQLite driver, so no cgo toolchain is needed
+	_ "modernc.org/sqlite"
+)
+
+// databaseSchema creates the tables on first use. Times are stored as UTC RFC3339 text,
+// which sorts chronologically.
+const databaseSchema = `
+CREATE TABLE IF NOT EXISTS emails (
+	email TEXT PRIMARY KEY,
+	domain TEXT NOT NULL,
+	name TEXT,
+	repository TEXT,
+	first_seen TEXT,
+	first_seen_repository TEXT,
+	first_seen_sha TEXT,
+	last_scanned TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS domains (
+	domain TEXT PRIMARY KEY,
+	type TEXT NOT NULL,
+	expiry_date TEXT,
+	status TEXT,
+	registrar TEXT,
+	checked_at TEXT,
+	last_scanned TEXT NOT NULL
+);`
+
+// upsertEmail keeps the repository of the scan that first found an email and its
+// earliest dated commit across scans; SET expressions all see the row before the update
+const upsertEmail = `
+INSERT INTO emails (email, domain, name, repository, first_seen, first_seen_repository, first_seen_sha, last_scanned)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (email) DO UPDATE SET
+	name = COALESCE(excluded.name, emails.name),
+	repository = COALESCE(emails.repository, excluded.repository),
+	first_seen = CASE WHEN excluded.first_seen IS NOT NULL AND (emails.first_seen IS NULL OR excluded.first_seen < emails.first_seen)
+		THEN excluded.first_seen ELSE emails.first_seen END,
+	first_seen_repository = CASE WHEN excluded.first_seen IS NOT NULL AND (emails.first_seen IS NULL OR excluded.first_seen < emails.first_seen)
+		THEN excluded.first_seen_repository ELSE emails.first_seen_repository END,
+	first_seen_sha = CASE WHEN excluded.first_seen IS NOT NULL AND (emails.first_seen IS NULL OR excluded.first_seen < emails.first_seen)
+		THEN excluded.first_seen_sha ELSE emails.first_seen_sha END,
+	last_scanned = excluded.last_scanned`
+
+const upsertDomain = `
+INSERT INTO domains (domain, type, last_scanned) VALUES (?, ?, ?)
+ON CONFLICT (domain) DO UPDATE SET type = excluded.type, last_scanned = excluded.last_scanned`
+
+// updateExpiry keeps the previously known expiry and registrar when a lookup finds none
+const updateExpiry = `
+UPDATE domains SET expiry_date = COALESCE(?, expiry_date), status = ?,
+	registrar = COALESCE(?, registrar), checked_at = ?
+WHERE domain = ?`
+
+// database accumulates the results of every scan in an SQLite file
+type database struct {
+	db      *sql.DB
+	scanned string
+}
+
+// openDatabase opens or creates the SQLite database at path and its tables
+func openDatabase(path string) (*database, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(databaseSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &database{db: db, scanned: time.Now().UTC().Format(time.RFC3339)}, nil
+}
+
+// saveEmails upserts every collected email with its first name, the repository that first
+// yielded it and its first dated commit, and the domains they belong to
+func (d *database) saveEmails(emailRepos map[string][]string, emailNames map[string]map[string]bool, foundIn map[string]string, firstSeen map[string]gemails.Sighting) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	domains := make(map[string]bool)
+	for email := range emailRepos {
+		domain := gemails.ExtractDomain(email)
+		if domain != "" {
+			domains[domain] = true
+		}
+
+		var name, seenDate, seenRepo, seenSHA interface{}
+		if names := sortedKeys(emailNames[email]); len(names) > 0 {
+			name = names[0]
+		}
+		if seen, ok := firstSeen[email]; ok {
+			seenDate, seenRepo, seenSHA = seen.Date.UTC().Format(time.RFC3339), seen.Repository, seen.SHA
+		}
+		if _, err := tx.Exec(upsertEmail, email, domain, name, nullString(foundIn[email]), seenDate, seenRepo, seenSHA, d.scanned); err != nil {
+			return err
+		}
+	}
+	for domain := range domains {
+		if _, err := tx.Exec(upsertDomain, domain, domainType(domain), d.scanned); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// saveExpiries records the outcome of the WHOIS checks on the domains already saved
+func (d *database) saveExpiries(w *whoisChecker, expiries []domainExpiry) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, result := range expiries {
+		var expiry interface{}
+		if !result.info.Expiry.IsZero() {
+			expiry = result.info.Expiry.Format("2006-01-02")
+		}
+		if _, err := tx.Exec(updateExpiry, expiry, w.status(result), nullString(result.info.Registrar), d.scanned, result.domain); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Close closes the database file
+func (d *database) Close() error {
+	return d.db.Close()
+}
+
+// nullString maps an empty string to SQL NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
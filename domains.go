@@ -0,0 +1,84 @@
+package main
+
+import "strings"
+
+// freeMailDomains is the lower-cased set of publicEmailProviders used to classify domains
+var freeMailDomains = lowerSet(publicEmailProviders)
+
+// domainType classifies domain as "free" when it belongs to a free-mail provider, otherwise "corporate"
+func domainType(domain string) string {
+	if freeMailDomains[strings.ToLower(domain)] {
+		return "free"
+	}
+	return "corporate"
+}
+
+// loadFreeMailDomains replaces the built-in free-mail provider list, used both to classify
+// domains and to skip WHOIS checks, with the domains listed in path
+func loadFreeMailDomains(path string) error {
+	domains, err := readList(path)
+	if err != nil {
+		return err
+	}
+	publicEmailProviders = domains
+	freeMailDomains = lowerSet(domains)
+	return nil
+}
+
+// lowerSet returns the lower-cased items as a set
+func lowerSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[strings.ToLower(item)] = true
+	}
+	return set
+}
+
+// secondLevelLabels are the second-level labels that, under a two-letter country TLD,
+// form part of the public suffix, as in example.co.uk
+var secondLevelLabels = map[string]bool{"co": true, "com": true, "net": true, "org": true, "ac": true, "gov": true, "edu": true}
+
+// registrableDomain returns the domain a name is registered under: example.com for
+// mail.example.com and example.co.uk for mail.example.co.uk, or "" for a bare label
+func registrableDomain(domain string) string {
+	labels := strings.Split(strings.ToLower(domain), ".")
+	n := len(labels)
+	switch {
+	case n < 2:
+		return ""
+	case n >= 3 && len(labels[n-1]) == 2 && secondLevelLabels[labels[n-2]]:
+		return strings.Join(labels[n-3:], ".")
+	}
+	return strings.Join(labels[n-2:], ".")
+}
+
+// registrableName returns the name a domain is registered under, without its TLD:
+// "example" for example.com, mail.example.com and example.co.uk
+func registrableName(domain string) string {
+	name := registrableDomain(domain)
+	return name[:strings.Index(name+".", ".")]
+}
+
+// siblingDomains returns the registrable name of each corporate domain under each of tlds,
+// leaving out the domains already in domains
+func siblingDomains(domains map[string]bool, tlds []string) map[string]bool {
+	known := make(map[string]bool, len(domains))
+	for domain := range domains {
+		known[strings.ToLower(domain)] = true
+	}
+
+	siblings := make(map[string]bool)
+	for domain := range domains {
+		name := registrableName(domain)
+		if name == "" || domainType(domain) != "corporate" {
+			continue
+		}
+		for _, tld := range tlds {
+			sibling := name + "." + strings.ToLower(strings.TrimPrefix(tld, "."))
+			if !known[sibling] {
+				siblings[sibling] = true
+			}
+		}
+	}
+	return siblings
+}
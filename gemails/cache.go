@@ -0,0 +1,95 @@
+package gemails
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CachedResponse is a successful API response kept by a ResponseCache: its body and the
+// rel="next" and rel="last" links of its page
+type CachedResponse struct {
+	Body []byte
+	Next string
+	Last string
+}
+
+// ResponseCache stores API responses by URL. A hit is served without sending the request
+// at all, so unlike ETags it saves the round trip, but it can serve stale data.
+type ResponseCache interface {
+	// Get returns the response cached for url, if there is a fresh one
+	Get(url string) (CachedResponse, bool)
+	// Set stores the response for url
+	Set(url string, resp CachedResponse) error
+}
+
+// DiskCache is a ResponseCache keeping one file per URL in a directory, fresh for a TTL.
+// Responses depend on the token's access, so a directory should not be shared by tokens.
+type DiskCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// diskCacheEntry is the content of a DiskCache file
+type diskCacheEntry struct {
+	URL     string          `json:"url"`
+	Fetched time.Time       `json:"fetched"`
+	Next    string          `json:"next,omitempty"`
+	Last    string          `json:"last,omitempty"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// NewDiskCache returns a DiskCache storing its files in dir, which is created if needed,
+// and serving them for ttl
+func NewDiskCache(dir string, ttl time.Duration) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir, ttl: ttl}, nil
+}
+
+// path returns the file caching url; URLs are hashed as they are not valid file names
+func (d *DiskCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get returns the response cached for url unless it is missing, unreadable or older than the TTL
+func (d *DiskCache) Get(url string) (CachedResponse, bool) {
+	data, err := ioutil.ReadFile(d.path(url))
+	if err != nil {
+		return CachedResponse{}, false
+	}
+	var entry diskCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != url || time.Since(entry.Fetched) >= d.ttl {
+		return CachedResponse{}, false
+	}
+	return CachedResponse{Body: entry.Body, Next: entry.Next, Last: entry.Last}, true
+}
+
+// Set writes the response for url, through a temporary file so that concurrent readers
+// never see a partial one
+func (d *DiskCache) Set(url string, resp CachedResponse) error {
+	data, err := json.Marshal(diskCacheEntry{URL: url, Fetched: time.Now(), Next: resp.Next, Last: resp.Last, Body: resp.Body})
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(d.dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), d.path(url))
+}
@@ -0,0 +1,171 @@
+package gemails
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// checkpointInterval is the minimum time between checkpoint writes during a scan
+const checkpointInterval = 10 * time.Second
+
+// Checkpoint records the repositories a scan has fully processed and the emails found in
+// them, so that an interrupted scan can resume without fetching them again.
+// It is safe for concurrent use.
+type Checkpoint struct {
+	mu    sync.Mutex
+	path  string
+	saved time.Time
+	repos map[string]*checkpointRepo
+}
+
+// checkpointRepo is a fully processed repository
+type checkpointRepo struct {
+	Source  string                      `json:"source"`
+	Commits int                         `json:"commits"`
+	Emails  map[string]*checkpointEmail `json:"emails"`
+}
+
+// checkpointEmail is what was kept of an email in one repository
+type checkpointEmail struct {
+	Names []string  `json:"names,omitempty"`
+	First *Sighting `json:"first,omitempty"`
+	Last  *Sighting `json:"last,omitempty"`
+	// Commits is the number of commits the email appeared in
+	Commits int `json:"commits,omitempty"`
+}
+
+// NewCheckpoint returns an empty checkpoint that is saved to path
+func NewCheckpoint(path string) *Checkpoint {
+	return &Checkpoint{path: path, repos: make(map[string]*checkpointRepo)}
+}
+
+// LoadCheckpoint reads the checkpoint saved at path to resume from it; a missing file yields
+// an empty checkpoint
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	cp := NewCheckpoint(path)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cp.repos); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// Len returns the number of repositories recorded as fully processed
+func (cp *Checkpoint) Len() int {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return len(cp.repos)
+}
+
+// Save writes the checkpoint atomically, through a temporary file renamed over the old one
+func (cp *Checkpoint) Save() error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.save()
+}
+
+func (cp *Checkpoint) save() error {
+	data, err := json.Marshal(cp.repos)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(cp.path), filepath.Base(cp.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), cp.path); err != nil {
+		return err
+	}
+	cp.saved = time.Now()
+	return nil
+}
+
+// lookup returns the saved repository key, if it was fully processed; cp may be nil
+func (cp *Checkpoint) lookup(key string) (*checkpointRepo, bool) {
+	if cp == nil {
+		return nil, false
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	repo, ok := cp.repos[key]
+	return repo, ok
+}
+
+// record marks repository key as fully processed, saving the checkpoint when the last write
+// is older than checkpointInterval
+func (cp *Checkpoint) record(key string, repo *checkpointRepo) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.repos[key] = repo
+	if time.Since(cp.saved) < checkpointInterval {
+		return nil
+	}
+	return cp.save()
+}
+
+// add records identity as kept from commit sha, tracking its earliest and latest sighting
+func (r *checkpointRepo) add(identity Identity, sha string) {
+	email := NormalizeEmail(identity.Email)
+	entry := r.Emails[email]
+	if entry == nil {
+		entry = &checkpointEmail{}
+		r.Emails[email] = entry
+	}
+	if identity.Name != "" && !containsString(entry.Names, identity.Name) {
+		entry.Names = append(entry.Names, identity.Name)
+	}
+	if identity.Date.IsZero() {
+		return
+	}
+	seen := Sighting{Repository: r.Source, SHA: sha, Date: identity.Date}
+	if entry.First == nil || seen.Date.Before(entry.First.Date) {
+		entry.First = &seen
+	}
+	if entry.Last == nil || seen.Date.After(entry.Last.Date) {
+		entry.Last = &seen
+	}
+}
+
+// replay adds the emails saved for the repository to col as if its commits had been fetched
+func (r *checkpointRepo) replay(col *collector) {
+	col.countRepository(r.Commits)
+	for email, entry := range r.Emails {
+		col.add(Identity{Email: email}, r.Source, "")
+		for _, seen := range []*Sighting{entry.First, entry.Last} {
+			if seen != nil {
+				col.add(Identity{Email: email, Date: seen.Date}, seen.Repository, seen.SHA)
+			}
+		}
+		for _, name := range entry.Names {
+			col.add(Identity{Name: name, Email: email}, r.Source, "")
+		}
+		col.countCommits(email, entry.Commits)
+	}
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
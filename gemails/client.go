@@ -0,0 +1,398 @@
+// Package gemails harvests committer email addresses from the repositories of a
+// GitHub user or organization.
+package gemails
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL is the GitHub REST API endpoint used when none is configured
+const DefaultBaseURL = "https://api.github.com"
+
+// DefaultUserAgent identifies the library to GitHub when no UserAgent is configured
+const DefaultUserAgent = "gemails"
+
+// Client talks to the GitHub API on behalf of a token
+type Client struct {
+	// Token is the GitHub API token sent with every request
+	Token string
+	// App, if set, authenticates as a GitHub App installation instead of with Token
+	App *AppAuth
+	// BaseURL is the GitHub API root, without a trailing slash
+	BaseURL string
+	// UserAgent is sent with every request; GitHub rejects requests without one
+	UserAgent string
+	// HTTPClient performs the requests; its timeout bounds a single stalled request.
+	// The default one honors the HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables.
+	HTTPClient *http.Client
+	// MaxRateLimitWait caps how long a request sleeps when rate limited before retrying
+	MaxRateLimitWait time.Duration
+	// RequestDelay, if positive, spaces out consecutive requests by at least that long,
+	// across all concurrent workers, to go easy on a shared token
+	RequestDelay time.Duration
+	// PageConcurrency is how many pages of a repository's commits are fetched in parallel
+	// once the first page reveals the last one; 1 or less fetches them one after the other
+	PageConcurrency int
+	// MaxAttempts is how many times a request is tried on 5xx responses and network errors
+	MaxAttempts int
+	// Concurrency is the number of repositories processed in parallel
+	Concurrency int
+	// IncludeNoreply keeps GitHub noreply addresses instead of filtering them
+	IncludeNoreply bool
+	// IncludeBots keeps the addresses of automation accounts instead of filtering them; see IsBot
+	IncludeBots bool
+	// EmailInclude, if non-empty, keeps only the addresses matching one of the patterns;
+	// EmailExclude drops those matching any. See CompileEmailPattern.
+	EmailInclude []*regexp.Regexp
+	EmailExclude []*regexp.Regexp
+	// OnEmail, if set, is called with each new unique email and the source it was first seen in
+	OnEmail func(email, source string)
+	// OnRepository, if set, is called when a repository of owner starts being processed;
+	// index counts from 1 up to the total number of repositories being processed
+	OnRepository func(owner string, repo Repository, index, total int)
+	// Logger receives warnings about skipped repositories and retried requests
+	Logger *log.Logger
+	// DebugLogger, if set, receives the verbose output instead of Logger
+	DebugLogger *log.Logger
+	// IncludePrivate also lists private repositories visible to the token (needs the repo scope)
+	IncludePrivate bool
+	// SkipForks leaves forked repositories out of CollectEmails
+	SkipForks bool
+	// SkipArchived leaves archived, read-only repositories out of CollectEmails
+	SkipArchived bool
+	// Starred makes CollectEmails process the repositories the account has starred
+	// instead of the ones it owns
+	Starred bool
+	// RepoOwner, if set, is the owner whose repositories of the listed names have their
+	// commits fetched, instead of the owner the listing reports
+	RepoOwner string
+	// MaxRepos, if positive, stops CollectEmails after that many repositories pass the filters
+	MaxRepos int
+	// Include, if non-empty, restricts CollectEmails to repositories whose name matches one
+	// of the patterns; Exclude skips those matching any. See CompilePattern.
+	Include []*regexp.Regexp
+	Exclude []*regexp.Regexp
+	// Branch, if set, restricts commit listings to that branch instead of the default one
+	Branch string
+	// Since, if non-zero, only fetches commits made after that time
+	Since time.Time
+	// RepoTimeout, if positive, bounds the time spent fetching a single repository; commits
+	// fetched before it expires are kept
+	RepoTimeout time.Duration
+	// MaxCommits stops fetching a repository's history after that many commits; 0 means unlimited
+	MaxCommits int
+	// MinCommits skips repositories whose history is shorter than that many commits, counted
+	// from the first page and the number of the last one, or from the last page itself when
+	// that is not enough; 0 keeps every repository.
+	MinCommits int
+	// PullRequests additionally collects the commits of every pull request
+	PullRequests bool
+	// Contributors additionally collects the public profile emails of each repository's contributors
+	Contributors bool
+	// Events additionally collects commit authors from the owner's public push events
+	Events bool
+	// Gists additionally collects the public profile emails of the accounts that committed
+	// to the owner's public gists, whose revisions carry no git emails
+	Gists bool
+	// Checkpoint, if set, records fully processed repositories and skips those it already lists
+	Checkpoint *Checkpoint
+	// ETags, if set, is used to send conditional requests and reuse bodies of unchanged pages
+	ETags *ETagCache
+	// Cache, if set, serves the GET responses it holds without sending the requests, and
+	// stores every 200 response
+	Cache ResponseCache
+	// RepoCache, if set, serves the repository listings of CollectEmails while they are fresh
+	RepoCache *RepoCache
+	// Verbose additionally logs every request, its status, and per-repository counts
+	Verbose bool
+
+	throttleMu  sync.Mutex
+	nextRequest time.Time
+}
+
+// StatusError reports an unexpected HTTP status returned by the GitHub API
+type StatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("GitHub API returned status code %d for URL %s", e.StatusCode, e.URL)
+}
+
+// NewClient returns a Client for the public GitHub API with sensible defaults
+func NewClient(token string) *Client {
+	return &Client{
+		Token:            token,
+		BaseURL:          DefaultBaseURL,
+		UserAgent:        DefaultUserAgent,
+		HTTPClient:       &http.Client{Timeout: 60 * time.Second, Transport: newTransport(http.ProxyFromEnvironment)},
+		MaxRateLimitWait: time.Hour,
+		MaxAttempts:      3,
+		Concurrency:      5,
+		PageConcurrency:  4,
+		Logger:           log.New(os.Stderr, "", log.LstdFlags),
+	}
+}
+
+// SetProxy routes all requests through the proxy at rawURL, e.g. http://host:3128 or socks5://host:1080
+func (c *Client) SetProxy(rawURL string) error {
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL %q: %w", rawURL, err)
+	}
+	c.HTTPClient.Transport = newTransport(http.ProxyURL(proxyURL))
+	return nil
+}
+
+// newTransport returns a copy of the default transport using the given proxy function
+func newTransport(proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	return transport
+}
+
+// logf writes a warning to the client's logger, if any
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, args...)
+	}
+}
+
+// debugf writes to the client's logger only in verbose mode
+func (c *Client) debugf(format string, args ...interface{}) {
+	if !c.Verbose {
+		return
+	}
+	if c.DebugLogger != nil {
+		c.DebugLogger.Printf(format, args...)
+		return
+	}
+	c.logf(format, args...)
+}
+
+// get sends a GET request to the provided URL with the GitHub token.
+// It returns the response body and the URL of the next page, if any.
+// A 409 Conflict or 204 No Content yields an empty body and no error; callers treat it as no data.
+func (c *Client) get(ctx context.Context, url string) ([]byte, string, error) {
+	body, links, err := c.getPage(ctx, url)
+	return body, links.Next, err
+}
+
+// pageLinks are the rel="next" and rel="last" URLs of a paginated response
+type pageLinks struct {
+	Next string
+	Last string
+}
+
+// getPage is get returning both the next and the last page links
+func (c *Client) getPage(ctx context.Context, url string) ([]byte, pageLinks, error) {
+	if c.Cache != nil {
+		if cached, ok := c.Cache.Get(url); ok {
+			c.debugf("GET %s served from the response cache", url)
+			return cached.Body, pageLinks{Next: cached.Next, Last: cached.Last}, nil
+		}
+	}
+
+	resp, err := c.do(ctx, url)
+	if err != nil {
+		return nil, pageLinks{}, err
+	}
+	defer resp.Body.Close()
+
+	// Reuse the cached body of a page that has not changed since the last run
+	if resp.StatusCode == http.StatusNotModified && c.ETags != nil {
+		if entry, ok := c.ETags.lookup(url); ok {
+			return entry.Body, pageLinks{Next: entry.Next, Last: entry.Last}, nil
+		}
+	}
+
+	// Handle different HTTP status codes, especially 409 Conflict
+	if resp.StatusCode == http.StatusConflict { // 409 Conflict, e.g. an empty repository
+		c.debugf("409 Conflict encountered for URL: %s. Skipping.", url)
+		return nil, pageLinks{}, nil // Skip this request and return an empty response
+	} else if resp.StatusCode == http.StatusNoContent {
+		return nil, pageLinks{}, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, pageLinks{}, &StatusError{StatusCode: resp.StatusCode, URL: url}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, pageLinks{}, fmt.Errorf("error reading response body: %w", err)
+	}
+	links := pageLinks{
+		Next: linkURL(resp.Header.Get("Link"), "next"),
+		Last: linkURL(resp.Header.Get("Link"), "last"),
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" && c.ETags != nil && json.Valid(body) {
+		c.ETags.store(url, etagEntry{ETag: etag, Next: links.Next, Last: links.Last, Body: body})
+	}
+	if c.Cache != nil && json.Valid(body) {
+		if err := c.Cache.Set(url, CachedResponse{Body: body, Next: links.Next, Last: links.Last}); err != nil {
+			c.logf("Warning: could not cache the response of %s: %v", url, err)
+		}
+	}
+	return body, links, nil
+}
+
+// do performs an authenticated GET request, retrying while rate limited and, with
+// exponential backoff, on 5xx responses and network errors.
+// The caller is responsible for checking the status code and closing the body.
+func (c *Client) do(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	token := c.Token
+	if c.App != nil {
+		if token, err = c.App.installationToken(ctx, c); err != nil {
+			return nil, fmt.Errorf("error authenticating as GitHub App: %w", err)
+		}
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	if c.ETags != nil {
+		if entry, ok := c.ETags.lookup(url); ok {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+	}
+
+	for attempt := 1; ; {
+		if err := c.throttle(ctx); err != nil {
+			return nil, fmt.Errorf("error sending request: %w", err)
+		}
+		c.debugf("GET %s", url)
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil || attempt >= c.MaxAttempts {
+				return nil, fmt.Errorf("error sending request: %w", err)
+			}
+			c.logf("Error sending request to %s (attempt %d/%d): %v", url, attempt, c.MaxAttempts, err)
+			if err := sleep(ctx, backoff(attempt)); err != nil {
+				return nil, fmt.Errorf("error sending request: %w", err)
+			}
+			attempt++
+			continue
+		}
+		c.debugf("GET %s returned status %d", url, resp.StatusCode)
+
+		// Transient server errors usually succeed on retry
+		if resp.StatusCode >= 500 && attempt < c.MaxAttempts {
+			resp.Body.Close()
+			c.logf("GitHub API returned status code %d for URL %s (attempt %d/%d)", resp.StatusCode, url, attempt, c.MaxAttempts)
+			if err := sleep(ctx, backoff(attempt)); err != nil {
+				return nil, fmt.Errorf("error sending request: %w", err)
+			}
+			attempt++
+			continue
+		}
+
+		// Sleep and retry the same request when rate limited
+		wait, limited := rateLimitWait(resp)
+		if !limited {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if wait > c.MaxRateLimitWait {
+			wait = c.MaxRateLimitWait
+		}
+		c.logf("Rate limited on URL %s, retrying in %s", url, wait.Round(time.Second))
+		if err := sleep(ctx, wait); err != nil {
+			return nil, fmt.Errorf("error sending request: %w", err)
+		}
+	}
+}
+
+// throttle waits until RequestDelay has passed since the previous request, reserving the
+// next slot so that concurrent callers are spaced out too
+func (c *Client) throttle(ctx context.Context) error {
+	if c.RequestDelay <= 0 {
+		return nil
+	}
+	c.throttleMu.Lock()
+	now := time.Now()
+	if c.nextRequest.Before(now) {
+		c.nextRequest = now
+	}
+	wait := c.nextRequest.Sub(now)
+	c.nextRequest = c.nextRequest.Add(c.RequestDelay)
+	c.throttleMu.Unlock()
+	return sleep(ctx, wait)
+}
+
+// backoff returns the delay before retry number attempt: 1s, 2s, 4s, ... plus up to 50% jitter
+func backoff(attempt int) time.Duration {
+	delay := time.Second << uint(attempt-1)
+	return delay + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// sleep waits for d or until ctx is done, whichever comes first
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitWait reports whether the response indicates a rate limit and how long to wait.
+// Secondary rate limits carry a Retry-After header; primary ones exhaust X-RateLimit-Remaining.
+func rateLimitWait(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			return time.Minute, true
+		}
+		wait := time.Until(time.Unix(reset, 0)) + time.Second
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
+
+// linkURL extracts the URL of relation rel, e.g. "next", from a GitHub Link header
+func linkURL(linkHeader, rel string) string {
+	for _, link := range strings.Split(linkHeader, ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="`+rel+`"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
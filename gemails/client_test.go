@@ -0,0 +1,243 @@
+package gemails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client whose requests are served by handler
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewClient("test-token")
+	client.BaseURL = server.URL
+	client.HTTPClient = server.Client()
+	client.Logger = nil
+	client.MaxRateLimitWait = 50 * time.Millisecond
+	return client
+}
+
+func TestGetSendsTokenAndUserAgent(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer test-token")
+		}
+		if got := r.Header.Get("User-Agent"); got != DefaultUserAgent {
+			t.Errorf("User-Agent = %q, want %q", got, DefaultUserAgent)
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+
+	if _, _, err := client.get(context.Background(), client.BaseURL+"/user"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+}
+
+func TestGetReturnsNextPage(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `<https://api.github.com/items?page=3>; rel="last", <https://api.github.com/items?page=2>; rel="next"`)
+		fmt.Fprint(w, `[]`)
+	}))
+
+	body, next, err := client.get(context.Background(), client.BaseURL+"/items")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if string(body) != "[]" {
+		t.Errorf("body = %q, want %q", body, "[]")
+	}
+	if want := "https://api.github.com/items?page=2"; next != want {
+		t.Errorf("next = %q, want %q", next, want)
+	}
+}
+
+func TestGetConflictYieldsEmptyBody(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Git Repository is empty."}`, http.StatusConflict)
+	}))
+
+	body, next, err := client.get(context.Background(), client.BaseURL+"/repos/o/empty/commits")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(body) != 0 || next != "" {
+		t.Errorf("get = %q, %q; want an empty body and no next page", body, next)
+	}
+}
+
+func TestGetUnexpectedStatus(t *testing.T) {
+	client := newTestClient(t, http.NotFoundHandler())
+
+	_, _, err := client.get(context.Background(), client.BaseURL+"/users/ghost")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("get error = %v, want a 404 StatusError", err)
+	}
+}
+
+func TestDoRetriesAfterRetryAfter(t *testing.T) {
+	var requests int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+
+	if _, _, err := client.get(context.Background(), client.BaseURL+"/user"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+}
+
+func TestDoCapsRateLimitWait(t *testing.T) {
+	var requests int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// The quota only resets in an hour, well beyond MaxRateLimitWait
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+
+	start := time.Now()
+	if _, _, err := client.get(context.Background(), client.BaseURL+"/user"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < client.MaxRateLimitWait || elapsed > 5*time.Second {
+		t.Errorf("waited %s, want about %s", elapsed, client.MaxRateLimitWait)
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+}
+
+func TestDoRateLimitWaitIsCancelable(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	client.MaxRateLimitWait = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := client.get(ctx, client.BaseURL+"/user"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("get error = %v, want the context deadline", err)
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		header  http.Header
+		limited bool
+		wait    time.Duration
+	}{
+		{"ok", http.StatusOK, http.Header{}, false, 0},
+		{"forbidden without limit headers", http.StatusForbidden, http.Header{}, false, 0},
+		{"retry after", http.StatusForbidden, http.Header{"Retry-After": {"30"}}, true, 30 * time.Second},
+		{"too many requests", http.StatusTooManyRequests, http.Header{"Retry-After": {"5"}}, true, 5 * time.Second},
+		{"exhausted without reset", http.StatusForbidden, http.Header{"X-Ratelimit-Remaining": {"0"}}, true, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, limited := rateLimitWait(&http.Response{StatusCode: tt.status, Header: tt.header})
+			if wait != tt.wait || limited != tt.limited {
+				t.Errorf("rateLimitWait = %s, %v; want %s, %v", wait, limited, tt.wait, tt.limited)
+			}
+		})
+	}
+}
+
+func TestLinkURL(t *testing.T) {
+	tests := []struct {
+		header string
+		rel    string
+		want   string
+	}{
+		{"", "next", ""},
+		{`<https://api.github.com/x?page=2>; rel="next"`, "next", "https://api.github.com/x?page=2"},
+		{`<https://api.github.com/x?page=1>; rel="prev", <https://api.github.com/x?page=3>; rel="next"`, "next", "https://api.github.com/x?page=3"},
+		{`<https://api.github.com/x?page=2>; rel="next", <https://api.github.com/x?page=9>; rel="last"`, "last", "https://api.github.com/x?page=9"},
+		{`<https://api.github.com/x?page=1>; rel="first"`, "next", ""},
+	}
+	for _, tt := range tests {
+		if got := linkURL(tt.header, tt.rel); got != tt.want {
+			t.Errorf("linkURL(%q, %q) = %q, want %q", tt.header, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestRequestDelaySpacesRequests(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	}))
+	client.RequestDelay = 20 * time.Millisecond
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, _, err := client.get(context.Background(), client.BaseURL+"/user"); err != nil {
+			t.Fatalf("get: %v", err)
+		}
+	}
+	// The first request goes out immediately, the next two wait a delay each
+	if elapsed := time.Since(start); elapsed < 2*client.RequestDelay {
+		t.Errorf("3 requests took %s, want at least %s", elapsed, 2*client.RequestDelay)
+	}
+}
+
+func TestDiskCacheServesResponses(t *testing.T) {
+	var requests int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Link", `<https://api.github.com/items?page=2>; rel="next"`)
+		fmt.Fprint(w, `[1]`)
+	})
+	client := newTestClient(t, handler)
+	cache, err := NewDiskCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewDiskCache: %v", err)
+	}
+	client.Cache = cache
+
+	for i := 0; i < 2; i++ {
+		body, next, err := client.get(context.Background(), client.BaseURL+"/items")
+		if err != nil || string(body) != "[1]" || next != "https://api.github.com/items?page=2" {
+			t.Fatalf("get %d = %q, %q, %v", i, body, next, err)
+		}
+		// Errors are never cached
+		client.get(context.Background(), client.BaseURL+"/missing")
+	}
+	if requests != 3 {
+		t.Errorf("requests = %d, want 3: one for the cached page and two for the missing one", requests)
+	}
+
+	cache.ttl = 0
+	if _, _, err := client.get(context.Background(), client.BaseURL+"/items"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if requests != 4 {
+		t.Errorf("requests = %d, want 4 once the cached page is stale", requests)
+	}
+}
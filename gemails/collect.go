@@ -0,0 +1,508 @@
+package gemails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result holds the emails collected from a set of repositories
+type Result struct {
+	// Emails maps each unique address to the sorted repositories it was seen in
+	Emails map[string][]string
+	// Names maps each address to the sorted, distinct names it was committed under
+	Names map[string][]string
+	// FirstSeen and LastSeen map each address to its earliest and latest dated commit
+	FirstSeen map[string]Sighting
+	LastSeen  map[string]Sighting
+	// CommitCounts maps each address to the number of commits it authored or committed;
+	// a commit counted in several repositories, e.g. forks, counts once in each
+	CommitCounts map[string]int
+	// FoundIn maps each address to the repository that first yielded it
+	FoundIn map[string]string
+	// FilteredNoreply counts the unique noreply addresses that were skipped
+	FilteredNoreply int
+	// FilteredBots counts the unique bot addresses that were skipped
+	FilteredBots int
+	// Rejected counts the unique malformed addresses that were skipped
+	Rejected int
+	// FilteredPattern counts the unique addresses dropped by EmailInclude and EmailExclude
+	FilteredPattern int
+	// Repositories and Commits count what was processed
+	Repositories int
+	Commits      int
+	// Errors lists the failures that left the collection incomplete, e.g. repositories
+	// whose commits could not all be fetched; they are also logged as they happen
+	Errors []error
+}
+
+// Sighting is a commit an email was recorded in
+type Sighting struct {
+	Repository string    `json:"repository"`
+	SHA        string    `json:"sha"`
+	Date       time.Time `json:"date"`
+}
+
+// CollectEmails fetches the repositories of owner kept by the client's filters, up to
+// MaxRepos of them, and collects the emails from their commits. With Starred, the
+// repositories owner has starred are processed instead of those it owns.
+func (c *Client) CollectEmails(ctx context.Context, owner string) (*Result, error) {
+	if c.RepoCache != nil {
+		repos, err := c.cachedRepos(ctx, owner)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching repositories: %w", err)
+		}
+		return c.CollectFromRepos(ctx, owner, repos), nil
+	}
+
+	url, err := c.listingURL(ctx, owner)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching repositories: %w", err)
+	}
+	repos, err := c.listRepos(ctx, url, owner, c.keepRepo, c.MaxRepos)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching repositories: %w", err)
+	}
+	return c.CollectFromRepos(ctx, owner, repos), nil
+}
+
+// listingURL returns the URL listing the repositories of owner to process
+func (c *Client) listingURL(ctx context.Context, owner string) (string, error) {
+	if c.Starred {
+		return c.starredURL(owner), nil
+	}
+	return c.reposURL(ctx, owner)
+}
+
+// cachedRepos returns the repositories of owner kept by the client's filters, up to MaxRepos
+// of them, from the full listing in RepoCache, fetching and caching it unless it is fresh.
+// Filtering the full listing lets the filters change between runs.
+func (c *Client) cachedRepos(ctx context.Context, owner string) ([]Repository, error) {
+	key := strings.ToLower(owner)
+	switch {
+	case c.Starred:
+		key += " starred"
+	case c.IncludePrivate:
+		key += " private"
+	}
+
+	all, fetched, ok := c.RepoCache.lookup(key)
+	if ok {
+		c.debugf("Using the %d repositories of %s cached at %s", len(all), owner, fetched.Format(time.RFC3339))
+	} else {
+		url, err := c.listingURL(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		if all, err = c.listRepos(ctx, url, owner, nil, 0); err != nil {
+			return nil, err
+		}
+		c.RepoCache.store(key, all)
+	}
+
+	var repos []Repository
+	for _, repo := range all {
+		if !c.keepRepo(repo) {
+			continue
+		}
+		repos = append(repos, repo)
+		if c.MaxRepos > 0 && len(repos) == c.MaxRepos {
+			break
+		}
+	}
+	return repos, nil
+}
+
+// keepRepo reports whether repo passes the client's filters
+func (c *Client) keepRepo(repo Repository) bool {
+	switch {
+	case c.SkipForks && repo.Fork:
+		c.debugf("Skipping fork %s", repo.Name)
+	case c.SkipArchived && repo.Archived:
+		c.debugf("Skipping archived repository %s", repo.Name)
+	case len(c.Include) > 0 && !matchesAny(c.Include, repo.Name):
+		c.debugf("Skipping %s, which matches no -include pattern", repo.Name)
+	case matchesAny(c.Exclude, repo.Name):
+		c.debugf("Skipping excluded repository %s", repo.Name)
+	default:
+		return true
+	}
+	return false
+}
+
+// CollectFromRepos collects the emails from the commits of the given repositories of owner,
+// plus those of owner's public push events when Events is set and of its gists with Gists.
+// Repositories whose commits cannot be fetched are logged, skipped and listed in Result.Errors.
+func (c *Client) CollectFromRepos(ctx context.Context, owner string, repos []Repository) *Result {
+	col := newCollector(c.IncludeNoreply, c.IncludeBots, c.EmailInclude, c.EmailExclude, func(email, source string) {
+		c.debugf("New email %s found in %s", email, source)
+		if c.OnEmail != nil {
+			c.OnEmail(email, source)
+		}
+	})
+
+	concurrency := c.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// Process repositories in parallel with a bounded pool of workers
+	type job struct {
+		repo  Repository
+		index int
+	}
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if c.OnRepository != nil {
+					c.OnRepository(owner, j.repo, j.index, len(repos))
+				}
+				c.collectRepo(ctx, col, owner, j.repo)
+			}
+		}()
+	}
+	for i, repo := range repos {
+		jobs <- job{repo: repo, index: i + 1}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if c.Events {
+		events, err := c.FetchPushEventCommits(ctx, owner)
+		if err != nil {
+			c.logf("Error fetching public events for %s: %v", owner, err)
+			col.addError(fmt.Errorf("public events of %s: %w", owner, err))
+		}
+		for _, commit := range events {
+			col.add(commit.Author, commit.Repo, commit.SHA)
+		}
+	}
+	if c.Gists {
+		c.collectGists(ctx, col, owner)
+	}
+
+	return col.result()
+}
+
+// collectRepo collects the emails from the commits of one repository of owner into col.
+// With a Checkpoint, repositories it lists are replayed from it instead of being fetched,
+// and fully fetched ones are recorded in it.
+func (c *Client) collectRepo(ctx context.Context, col *collector, owner string, repo Repository) {
+	// Drain the remaining repositories quietly once the scan is cancelled
+	if ctx.Err() != nil {
+		return
+	}
+	// The listed owner can differ from the queried account, e.g. for org repos
+	repoOwner := repo.OwnerLogin(owner)
+	if c.RepoOwner != "" {
+		repoOwner = c.RepoOwner
+	}
+	key := repoOwner + "/" + repo.Name
+	// Repositories of other accounts, e.g. starred ones, are named with their owner
+	source := repo.Name
+	if !strings.EqualFold(repoOwner, owner) {
+		source = key
+	}
+	if saved, ok := c.Checkpoint.lookup(key); ok {
+		c.debugf("Repository %s was already processed according to the checkpoint", key)
+		saved.replay(col)
+		return
+	}
+
+	// A repository with an enormous history must not stall the whole scan
+	parent := ctx
+	if c.RepoTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RepoTimeout)
+		defer cancel()
+	}
+
+	complete := true
+	commits, err := c.FetchCommits(ctx, repoOwner, repo.Name)
+	switch {
+	case errors.Is(err, ErrTooFewCommits):
+		c.debugf("Skipping repository: %v", err)
+		return
+	case errors.Is(err, ErrUnavailable):
+		// Nothing more can be fetched, so the repository counts as processed
+		c.logf("Skipping repository: %v", err)
+	case err != nil:
+		// Keep whatever was fetched and move on to the next repository
+		c.logf("Error fetching commits for repo %s: %v", repo.Name, err)
+		col.addError(fmt.Errorf("commits of %s: %w", key, err))
+		complete = false
+	}
+	if c.PullRequests {
+		pullCommits, err := c.FetchPullRequestCommits(ctx, repoOwner, repo.Name)
+		if err != nil {
+			c.logf("Error fetching pull request commits for repo %s: %v", repo.Name, err)
+			col.addError(fmt.Errorf("pull request commits of %s: %w", key, err))
+			complete = false
+		}
+		commits = append(commits, pullCommits...)
+	}
+	if ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+		c.logf("Warning: repository %s exceeded the %s per-repository timeout and was abandoned; keeping the %d commits fetched", key, c.RepoTimeout, len(commits))
+	}
+
+	col.countRepository(len(commits))
+	saved := &checkpointRepo{Source: source, Commits: len(commits), Emails: make(map[string]*checkpointEmail)}
+	// An email counts once per commit, even as both its author and committer or when the
+	// commit is listed again in a pull request
+	counted := make(map[string]bool)
+	for _, commit := range commits {
+		if commit.CommitData.Author.Email == "" && commit.CommitData.Committer.Email == "" {
+			c.debugf("Commit %s of %s has no author or committer email", commit.SHA, key)
+		}
+		// Record both the author and the committer, as they often differ
+		for _, identity := range []Identity{commit.CommitData.Author, commit.CommitData.Committer} {
+			if !col.add(identity, source, commit.SHA) {
+				continue
+			}
+			saved.add(identity, commit.SHA)
+			email := NormalizeEmail(identity.Email)
+			if key := email + " " + commit.SHA; !counted[key] {
+				counted[key] = true
+				col.countCommits(email, 1)
+				saved.Emails[email].Commits++
+			}
+		}
+	}
+	if c.Contributors {
+		if err := c.collectContributors(ctx, col, saved, repoOwner, repo.Name, source); err != nil {
+			c.logf("Error fetching contributors for repo %s: %v", repo.Name, err)
+			col.addError(fmt.Errorf("contributors of %s: %w", key, err))
+			complete = false
+		}
+	}
+	c.debugf("Repository %s: %d commits, %d emails", repo.Name, len(commits), len(saved.Emails))
+
+	// Partially fetched repositories are fetched again when resuming
+	if c.Checkpoint != nil && complete {
+		if err := c.Checkpoint.record(key, saved); err != nil {
+			c.logf("Error saving checkpoint: %v", err)
+			col.addError(fmt.Errorf("checkpoint of %s: %w", key, err))
+		}
+	}
+}
+
+// collectContributors adds the public profile emails of the repository's contributors to col
+// and saved. Profiles are looked up once per login for the whole collection.
+func (c *Client) collectContributors(ctx context.Context, col *collector, saved *checkpointRepo, repoOwner, repo, source string) error {
+	contributors, err := c.FetchContributors(ctx, repoOwner, repo)
+	for _, contributor := range contributors {
+		identity, ok := col.profile(contributor.Login)
+		if !ok {
+			var profileErr error
+			if identity, profileErr = c.FetchProfile(ctx, contributor.Login); profileErr != nil {
+				c.logf("Error fetching profile of %s: %v", contributor.Login, profileErr)
+				col.addError(fmt.Errorf("profile of %s: %w", contributor.Login, profileErr))
+				continue
+			}
+			col.storeProfile(contributor.Login, identity)
+		}
+		if col.add(identity, source, "") {
+			saved.add(identity, "")
+		}
+	}
+	return err
+}
+
+// collector accumulates emails, their sources and names; it is safe for concurrent use
+type collector struct {
+	mu              sync.Mutex
+	includeNoreply  bool
+	includeBots     bool
+	emailInclude    []*regexp.Regexp
+	emailExclude    []*regexp.Regexp
+	onEmail         func(email, source string)
+	emailRepos      map[string]map[string]bool
+	emailNames      map[string]map[string]bool
+	firstSeen       map[string]Sighting
+	lastSeen        map[string]Sighting
+	foundIn         map[string]string
+	commitCounts    map[string]int
+	filteredNoreply map[string]bool
+	filteredBots    map[string]bool
+	rejected        map[string]bool
+	filteredPattern map[string]bool
+	profiles        map[string]Identity
+	repositories    int
+	commits         int
+	errors          []error
+}
+
+func newCollector(includeNoreply, includeBots bool, emailInclude, emailExclude []*regexp.Regexp, onEmail func(email, source string)) *collector {
+	return &collector{
+		includeNoreply:  includeNoreply,
+		includeBots:     includeBots,
+		emailInclude:    emailInclude,
+		emailExclude:    emailExclude,
+		onEmail:         onEmail,
+		emailRepos:      make(map[string]map[string]bool),
+		emailNames:      make(map[string]map[string]bool),
+		firstSeen:       make(map[string]Sighting),
+		lastSeen:        make(map[string]Sighting),
+		foundIn:         make(map[string]string),
+		commitCounts:    make(map[string]int),
+		filteredNoreply: make(map[string]bool),
+		filteredBots:    make(map[string]bool),
+		rejected:        make(map[string]bool),
+		filteredPattern: make(map[string]bool),
+		profiles:        make(map[string]Identity),
+	}
+}
+
+// profile returns the cached profile identity of login, if it was already fetched
+func (col *collector) profile(login string) (Identity, bool) {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	identity, ok := col.profiles[login]
+	return identity, ok
+}
+
+// storeProfile caches the profile identity of login
+func (col *collector) storeProfile(login string, identity Identity) {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.profiles[login] = identity
+}
+
+// addError records a failure that left the collection incomplete
+func (col *collector) addError(err error) {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.errors = append(col.errors, err)
+}
+
+// countRepository records a processed repository and its number of commits
+func (col *collector) countRepository(commits int) {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.repositories++
+	col.commits += commits
+}
+
+// countCommits adds n to the number of commits email appeared in
+func (col *collector) countCommits(email string, n int) {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	col.commitCounts[email] += n
+}
+
+// add records identity as seen in commit sha of source and reports whether its email was kept.
+// The email is normalized first so that near-duplicates collapse into one address.
+func (col *collector) add(identity Identity, source, sha string) bool {
+	email := NormalizeEmail(identity.Email)
+	if email == "" {
+		return false
+	}
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+
+	// Bots are checked first: most commit with a noreply address, and the brackets of
+	// dependabot[bot] and the like are not valid in an address
+	if !col.includeBots && IsBot(email) {
+		col.filteredBots[email] = true
+		return false
+	}
+	// Kept bot addresses are GitHub's own, so their brackets do not make them invalid
+	if !IsValidEmail(email) && !IsBot(email) {
+		col.rejected[email] = true
+		return false
+	}
+	if !col.includeNoreply && IsNoreply(email) {
+		col.filteredNoreply[email] = true
+		return false
+	}
+	if (len(col.emailInclude) > 0 && !matchesAny(col.emailInclude, email)) || matchesAny(col.emailExclude, email) {
+		col.filteredPattern[email] = true
+		return false
+	}
+	if col.emailRepos[email] == nil {
+		col.emailRepos[email] = make(map[string]bool)
+		col.foundIn[email] = source
+		if col.onEmail != nil {
+			col.onEmail(email, source)
+		}
+	}
+	col.emailRepos[email][source] = true
+	if identity.Name != "" {
+		if col.emailNames[email] == nil {
+			col.emailNames[email] = make(map[string]bool)
+		}
+		col.emailNames[email][identity.Name] = true
+	}
+	// Undated identities, e.g. from push events, cannot be placed in time
+	if !identity.Date.IsZero() {
+		seen := Sighting{Repository: source, SHA: sha, Date: identity.Date}
+		if first, ok := col.firstSeen[email]; !ok || seen.Date.Before(first.Date) {
+			col.firstSeen[email] = seen
+		}
+		if last, ok := col.lastSeen[email]; !ok || seen.Date.After(last.Date) {
+			col.lastSeen[email] = seen
+		}
+	}
+	return true
+}
+
+// result converts the accumulated sets into a Result with sorted lists
+func (col *collector) result() *Result {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+
+	result := &Result{
+		Emails:          make(map[string][]string, len(col.emailRepos)),
+		Names:           make(map[string][]string, len(col.emailNames)),
+		FirstSeen:       make(map[string]Sighting, len(col.firstSeen)),
+		LastSeen:        make(map[string]Sighting, len(col.lastSeen)),
+		FoundIn:         make(map[string]string, len(col.foundIn)),
+		CommitCounts:    make(map[string]int, len(col.commitCounts)),
+		FilteredNoreply: len(col.filteredNoreply),
+		FilteredBots:    len(col.filteredBots),
+		Rejected:        len(col.rejected),
+		FilteredPattern: len(col.filteredPattern),
+		Repositories:    col.repositories,
+		Commits:         col.commits,
+		Errors:          append([]error(nil), col.errors...),
+	}
+	for email, repoSet := range col.emailRepos {
+		result.Emails[email] = sortedKeys(repoSet)
+	}
+	for email, nameSet := range col.emailNames {
+		result.Names[email] = sortedKeys(nameSet)
+	}
+	for email, seen := range col.firstSeen {
+		result.FirstSeen[email] = seen
+	}
+	for email, seen := range col.lastSeen {
+		result.LastSeen[email] = seen
+	}
+	for email, source := range col.foundIn {
+		result.FoundIn[email] = source
+	}
+	for email, n := range col.commitCounts {
+		result.CommitCounts[email] = n
+	}
+	return result
+}
+
+// sortedKeys returns the keys of a set in sorted order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
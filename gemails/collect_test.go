@@ -0,0 +1,215 @@
+package gemails
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// newTestAccount serves the "octo" user with the repositories alpha and beta and their commits
+func newTestAccount(t *testing.T) *Client {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/octo", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"login": "octo", "type": "User"}`)
+	})
+	mux.HandleFunc("/users/octo/repos", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name": "alpha", "owner": {"login": "octo"}}, {"name": "beta", "fork": true, "owner": {"login": "octo"}}]`)
+	})
+	mux.Handle("/repos/octo/alpha/commits", pagedCommits(t, []map[string]interface{}{
+		commitJSON("a1", "Ann", "ann@example.com"),
+		commitJSON("a2", "Octocat", "123+octocat@users.noreply.github.com"),
+		commitJSON("a3", "Nobody", "none"),
+		commitJSON("a4", "Ann Smith", " <ann@example.com> "),
+	}, 2))
+	mux.Handle("/repos/octo/beta/commits", pagedCommits(t, []map[string]interface{}{
+		commitJSON("b1", "Bob", "bob@example.org"),
+		commitJSON("b2", "Ann", "ann@example.com"),
+		commitJSON("b3", "dependabot[bot]", "49699333+dependabot[bot]@users.noreply.github.com"),
+	}, 100))
+	client := newTestClient(t, mux)
+	client.Concurrency = 1
+	return client
+}
+
+func TestCollectEmails(t *testing.T) {
+	client := newTestAccount(t)
+
+	result, err := client.CollectEmails(context.Background(), "octo")
+	if err != nil {
+		t.Fatalf("CollectEmails: %v", err)
+	}
+
+	wantEmails := map[string][]string{
+		"ann@example.com": {"alpha", "beta"},
+		"bob@example.org": {"beta"},
+	}
+	if !reflect.DeepEqual(result.Emails, wantEmails) {
+		t.Errorf("Emails = %v, want %v", result.Emails, wantEmails)
+	}
+	if want := []string{"Ann", "Ann Smith"}; !reflect.DeepEqual(result.Names["ann@example.com"], want) {
+		t.Errorf("Names = %v, want %v", result.Names["ann@example.com"], want)
+	}
+	if got := result.CommitCounts["ann@example.com"]; got != 3 {
+		t.Errorf("CommitCounts = %d, want 3", got)
+	}
+	if got := result.FoundIn["ann@example.com"]; got != "alpha" {
+		t.Errorf("FoundIn = %q, want %q", got, "alpha")
+	}
+	if result.FilteredNoreply != 1 || result.FilteredBots != 1 || result.Rejected != 1 {
+		t.Errorf("FilteredNoreply, FilteredBots, Rejected = %d, %d, %d; want 1, 1, 1", result.FilteredNoreply, result.FilteredBots, result.Rejected)
+	}
+	if result.Repositories != 2 || result.Commits != 7 {
+		t.Errorf("Repositories, Commits = %d, %d; want 2, 7", result.Repositories, result.Commits)
+	}
+}
+
+func TestCollectEmailsFilters(t *testing.T) {
+	client := newTestAccount(t)
+	client.SkipForks = true
+	client.IncludeNoreply = true
+	client.IncludeBots = true
+
+	result, err := client.CollectEmails(context.Background(), "octo")
+	if err != nil {
+		t.Fatalf("CollectEmails: %v", err)
+	}
+
+	wantEmails := map[string][]string{
+		"ann@example.com":                      {"alpha"},
+		"123+octocat@users.noreply.github.com": {"alpha"},
+	}
+	if !reflect.DeepEqual(result.Emails, wantEmails) {
+		t.Errorf("Emails = %v, want %v", result.Emails, wantEmails)
+	}
+	if result.Repositories != 1 {
+		t.Errorf("Repositories = %d, want 1", result.Repositories)
+	}
+}
+
+func TestCollectEmailsPatterns(t *testing.T) {
+	client := newTestAccount(t)
+	include, _ := CompileEmailPattern(`@EXAMPLE\.(com|org)$`)
+	exclude, _ := CompileEmailPattern(`^bob@`)
+	client.EmailInclude = []*regexp.Regexp{include}
+	client.EmailExclude = []*regexp.Regexp{exclude}
+
+	result, err := client.CollectEmails(context.Background(), "octo")
+	if err != nil {
+		t.Fatalf("CollectEmails: %v", err)
+	}
+	if want := map[string][]string{"ann@example.com": {"alpha", "beta"}}; !reflect.DeepEqual(result.Emails, want) {
+		t.Errorf("Emails = %v, want %v", result.Emails, want)
+	}
+	if result.FilteredPattern != 1 {
+		t.Errorf("FilteredPattern = %d, want 1", result.FilteredPattern)
+	}
+}
+
+func TestCollectEmailsReportsNewEmails(t *testing.T) {
+	client := newTestAccount(t)
+	seen := make(map[string]string)
+	client.OnEmail = func(email, source string) {
+		if _, ok := seen[email]; ok {
+			t.Errorf("OnEmail called twice for %s", email)
+		}
+		seen[email] = source
+	}
+
+	if _, err := client.CollectEmails(context.Background(), "octo"); err != nil {
+		t.Fatalf("CollectEmails: %v", err)
+	}
+	want := map[string]string{"ann@example.com": "alpha", "bob@example.org": "beta"}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("OnEmail saw %v, want %v", seen, want)
+	}
+}
+
+func TestCollectEmailsListsFailedRepositories(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/octo", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"login": "octo", "type": "User"}`)
+	})
+	mux.HandleFunc("/users/octo/repos", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name": "alpha", "owner": {"login": "octo"}}, {"name": "broken", "owner": {"login": "octo"}}, {"name": "gone", "owner": {"login": "octo"}}]`)
+	})
+	mux.Handle("/repos/octo/alpha/commits", pagedCommits(t, []map[string]interface{}{commitJSON("a1", "Ann", "ann@example.com")}, 100))
+	mux.HandleFunc("/repos/octo/broken/commits", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.Handle("/repos/octo/gone/commits", http.NotFoundHandler())
+	client := newTestClient(t, mux)
+	client.MaxAttempts = 1
+
+	result, err := client.CollectEmails(context.Background(), "octo")
+	if err != nil {
+		t.Fatalf("CollectEmails: %v", err)
+	}
+	// Unavailable repositories are skipped as expected, not as failures
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Error(), "octo/broken") {
+		t.Fatalf("Errors = %v, want one for octo/broken", result.Errors)
+	}
+	if _, ok := result.Emails["ann@example.com"]; !ok {
+		t.Errorf("Emails = %v, want the ones of the other repositories", result.Emails)
+	}
+}
+
+func TestCollectEmailsGists(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/octo", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"login": "octo", "type": "User", "name": "Octo", "email": "octo@example.com"}`)
+	})
+	mux.HandleFunc("/users/octo/repos", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("/users/octo/gists", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": "g1"}, {"id": "deleted"}]`)
+	})
+	mux.HandleFunc("/gists/g1/commits", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"version": "v2", "user": {"login": "octo"}, "committed_at": "2024-02-01T00:00:00Z"},
+			{"version": "v1", "user": null, "committed_at": "2024-01-01T00:00:00Z"}
+		]`)
+	})
+	mux.Handle("/gists/deleted/commits", http.NotFoundHandler())
+	client := newTestClient(t, mux)
+	client.Gists = true
+
+	result, err := client.CollectEmails(context.Background(), "octo")
+	if err != nil {
+		t.Fatalf("CollectEmails: %v", err)
+	}
+	if want := map[string][]string{"octo@example.com": {"gist:g1"}}; !reflect.DeepEqual(result.Emails, want) {
+		t.Errorf("Emails = %v, want %v", result.Emails, want)
+	}
+	if got := result.FirstSeen["octo@example.com"]; got.SHA != "v2" || got.Date.Year() != 2024 {
+		t.Errorf("FirstSeen = %+v, want the v2 revision", got)
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("Errors = %v, want none for a deleted gist", result.Errors)
+	}
+}
+
+func TestCollectEmailsRepoOwner(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/member", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"login": "member", "type": "User"}`)
+	})
+	mux.HandleFunc("/users/member/repos", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name": "tool", "fork": true, "owner": {"login": "member"}}]`)
+	})
+	mux.Handle("/repos/org/tool/commits", pagedCommits(t, []map[string]interface{}{commitJSON("t1", "Ann", "ann@example.com")}, 100))
+	client := newTestClient(t, mux)
+	client.RepoOwner = "org"
+
+	result, err := client.CollectEmails(context.Background(), "member")
+	if err != nil {
+		t.Fatalf("CollectEmails: %v", err)
+	}
+	if want := map[string][]string{"ann@example.com": {"org/tool"}}; !reflect.DeepEqual(result.Emails, want) {
+		t.Errorf("Emails = %v, want %v", result.Emails, want)
+	}
+}
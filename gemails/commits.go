@@ -0,0 +1,332 @@
+package gemails
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Identity is the name, email and timestamp recorded for a commit's author or committer
+type Identity struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+// UnmarshalJSON decodes an identity leniently, so that one malformed commit cannot fail a
+// whole page: a null identity, a field of an unexpected type or an unparseable date is
+// left empty while the remaining fields are still extracted.
+func (id *Identity) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		// null, or not an object at all
+		return nil
+	}
+	*id = Identity{}
+	json.Unmarshal(fields["name"], &id.Name)
+	json.Unmarshal(fields["email"], &id.Email)
+	var date string
+	if json.Unmarshal(fields["date"], &date) == nil {
+		id.Date, _ = time.Parse(time.RFC3339, date)
+	}
+	return nil
+}
+
+// Commit represents a GitHub commit. Only the git author and committer recorded in the
+// commit are used; the top-level GitHub accounts are null for commits not linked to one.
+type Commit struct {
+	SHA        string `json:"sha"`
+	CommitData struct {
+		Author    Identity `json:"author"`
+		Committer Identity `json:"committer"`
+	} `json:"commit"`
+}
+
+// ErrUnavailable is wrapped by the errors of repositories whose commits cannot be listed at
+// all, e.g. because they were removed or taken down; retrying them is pointless
+var ErrUnavailable = errors.New("repository unavailable")
+
+// ErrTooFewCommits is wrapped by the errors of repositories skipped for having fewer
+// commits than the client's MinCommits
+var ErrTooFewCommits = errors.New("too few commits")
+
+// FetchCommits fetches all commits for a given repository, following pagination.
+// On error it returns the commits gathered so far alongside the error.
+// Empty repositories (409) yield no commits and no error.
+func (c *Client) FetchCommits(ctx context.Context, userOrOrg, repo string) ([]Commit, error) {
+	pageURL := fmt.Sprintf("%s/repos/%s/%s/commits?per_page=100", c.BaseURL, userOrOrg, repo)
+	if c.Branch != "" {
+		pageURL += "&sha=" + url.QueryEscape(c.Branch)
+	}
+	if !c.Since.IsZero() {
+		pageURL += "&since=" + url.QueryEscape(c.Since.UTC().Format(time.RFC3339))
+	}
+
+	commits, err := c.fetchCommitPages(ctx, pageURL, userOrOrg+"/"+repo, c.MinCommits, c.MaxCommits)
+	if errors.Is(err, ErrTooFewCommits) {
+		return nil, fmt.Errorf("%w: %s/%s has %d, fewer than %d", ErrTooFewCommits, userOrOrg, repo, len(commits), c.MinCommits)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return commits, err
+	}
+	switch code := statusErr.StatusCode; {
+	case c.Branch != "" && (code == http.StatusNotFound || code == http.StatusUnprocessableEntity):
+		return commits, fmt.Errorf("%w: branch %q not found in %s/%s", ErrUnavailable, c.Branch, userOrOrg, repo)
+	case code == http.StatusNotFound:
+		return commits, fmt.Errorf("%w: %s/%s was not found or the token cannot access it", ErrUnavailable, userOrOrg, repo)
+	case code == http.StatusUnavailableForLegalReasons:
+		return commits, fmt.Errorf("%w: %s/%s is blocked for legal reasons, e.g. a DMCA takedown", ErrUnavailable, userOrOrg, repo)
+	}
+	return commits, err
+}
+
+// PullRequest represents a GitHub pull request
+type PullRequest struct {
+	Number int `json:"number"`
+}
+
+// FetchPullRequestCommits fetches the commits of every pull request, open or closed, of a repository.
+// On error it returns the commits gathered so far alongside the error.
+func (c *Client) FetchPullRequestCommits(ctx context.Context, userOrOrg, repo string) ([]Commit, error) {
+	pageURL := fmt.Sprintf("%s/repos/%s/%s/pulls?state=all&per_page=100", c.BaseURL, userOrOrg, repo)
+
+	var pulls []PullRequest
+	for page := 1; pageURL != ""; page++ {
+		c.debugf("Fetching pull requests page %d for %s/%s", page, userOrOrg, repo)
+		response, next, err := c.get(ctx, pageURL)
+		if err != nil {
+			return nil, err
+		}
+		if len(response) == 0 {
+			break // 409 Conflict (empty repository), no pull requests
+		}
+
+		var pagePulls []PullRequest
+		if err := json.Unmarshal(response, &pagePulls); err != nil {
+			return nil, fmt.Errorf("error unmarshaling pull requests for repo %s: %w", repo, err)
+		}
+		pulls = append(pulls, pagePulls...)
+		pageURL = next
+	}
+
+	var commits []Commit
+	for _, pull := range pulls {
+		pageURL := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/commits?per_page=100", c.BaseURL, userOrOrg, repo, pull.Number)
+		pullCommits, err := c.fetchCommitPages(ctx, pageURL, fmt.Sprintf("%s/%s#%d", userOrOrg, repo, pull.Number), 0, 0)
+		commits = append(commits, pullCommits...)
+		if err != nil {
+			return commits, err
+		}
+	}
+	return commits, nil
+}
+
+// fetchCommitPages accumulates the commits of a paginated commit listing, described by
+// desc in log and error messages, stopping after limit commits unless limit is 0.
+// A listing with fewer than min commits yields ErrTooFewCommits, judged from the first page
+// and its rel="last" link when they settle it.
+// When the first page links to the last one, the remaining pages are fetched in parallel.
+// On error it returns the commits gathered so far.
+func (c *Client) fetchCommitPages(ctx context.Context, pageURL, desc string, min, limit int) ([]Commit, error) {
+	var commits []Commit
+	for page := 1; pageURL != ""; page++ {
+		c.debugf("Fetching commits page %d for %s (%d commits so far)", page, desc, len(commits))
+		response, links, err := c.getPage(ctx, pageURL)
+		if err != nil {
+			return commits, err
+		}
+		if len(response) == 0 {
+			break // 409 Conflict (empty repository), even mid-pagination: no more commits
+		}
+
+		pageCommits, perPage, err := c.decodeCommits(response, desc)
+		if err != nil {
+			return commits, err
+		}
+		commits = append(commits, pageCommits...)
+		if page == 1 && min > 0 {
+			tooFew, err := c.tooFewCommits(ctx, links, desc, perPage, min)
+			if err != nil {
+				return commits, err
+			}
+			if tooFew {
+				return commits, ErrTooFewCommits
+			}
+		}
+		if limit > 0 && len(commits) >= limit {
+			c.debugf("Reached the limit of %d commits for %s", limit, desc)
+			return commits[:limit], nil
+		}
+
+		if urls := pageRange(links.Last, perPage, limit); page == 1 && c.PageConcurrency > 1 && len(urls) > 0 {
+			c.debugf("Fetching commits pages 2-%d for %s in parallel", len(urls)+1, desc)
+			rest, err := c.fetchCommitPagesParallel(ctx, urls, desc)
+			commits = uniqueCommits(append(commits, rest...))
+			if limit > 0 && len(commits) > limit {
+				commits = commits[:limit]
+			}
+			return commits, err
+		}
+		pageURL = links.Next
+	}
+	// Without a numbered rel="last" link, the count is only known once the listing has been
+	// followed; empty repositories are left to the caller as before
+	if len(commits) > 0 && len(commits) < min {
+		return commits, ErrTooFewCommits
+	}
+	return commits, nil
+}
+
+// tooFewCommits reports whether a listing whose first page of perPage commits has the given
+// links holds fewer than min commits in all. Every page but the last is full, so the number
+// of the last page bounds the count; only when min falls between the bounds is the last page
+// fetched to count its commits. Listings without a numbered last page are not judged here.
+func (c *Client) tooFewCommits(ctx context.Context, links pageLinks, desc string, perPage, min int) (bool, error) {
+	if links.Next == "" {
+		return perPage < min, nil
+	}
+	last, ok := lastPage(links.Last)
+	if !ok {
+		return false, nil
+	}
+	switch {
+	case (last-1)*perPage+1 >= min:
+		return false, nil
+	case last*perPage < min:
+		return true, nil
+	}
+
+	c.debugf("Fetching the last commits page of %s to count its commits", desc)
+	response, _, err := c.getPage(ctx, links.Last)
+	if err != nil {
+		return false, err
+	}
+	lastCount := 0
+	if len(response) > 0 {
+		if _, lastCount, err = c.decodeCommits(response, desc); err != nil {
+			return false, err
+		}
+	}
+	return (last-1)*perPage+lastCount < min, nil
+}
+
+// fetchCommitPagesParallel fetches the given pages of a commit listing with a bounded pool
+// of workers and returns their commits in page order, up to the first page that failed
+func (c *Client) fetchCommitPagesParallel(ctx context.Context, urls []string, desc string) ([]Commit, error) {
+	pages := make([][]Commit, len(urls))
+	errs := make([]error, len(urls))
+	ends := make([]bool, len(urls))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < c.PageConcurrency && i < len(urls); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				response, _, err := c.getPage(ctx, urls[i])
+				switch {
+				case err != nil:
+					errs[i] = err
+				case len(response) == 0:
+					ends[i] = true
+				default:
+					pages[i], _, errs[i] = c.decodeCommits(response, desc)
+				}
+			}
+		}()
+	}
+	for i := range urls {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var commits []Commit
+	for i := range urls {
+		if errs[i] != nil {
+			return commits, errs[i]
+		}
+		if ends[i] {
+			break
+		}
+		commits = append(commits, pages[i]...)
+	}
+	return commits, nil
+}
+
+// decodeCommits decodes a page of commits, each on its own so that a malformed one is
+// skipped instead of failing the whole page. It also returns the page's number of entries.
+func (c *Client) decodeCommits(response []byte, desc string) ([]Commit, int, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(response, &entries); err != nil {
+		return nil, 0, fmt.Errorf("error unmarshaling commits for %s: %w", desc, err)
+	}
+	commits := make([]Commit, 0, len(entries))
+	for _, data := range entries {
+		var commit Commit
+		if err := json.Unmarshal(data, &commit); err != nil {
+			c.logf("Warning: skipping a malformed commit of %s: %v", desc, err)
+			continue
+		}
+		commits = append(commits, commit)
+	}
+	return commits, len(entries), nil
+}
+
+// pageRange returns the URLs of pages 2 up to the one of lastURL, or only as many as needed
+// for limit commits of perPage each when limit is positive. It returns nil when lastURL
+// carries no page number, and the listing has to be followed serially.
+func pageRange(lastURL string, perPage, limit int) []string {
+	last, ok := lastPage(lastURL)
+	if !ok {
+		return nil
+	}
+	u, _ := url.Parse(lastURL)
+	query := u.Query()
+	if limit > 0 && perPage > 0 {
+		if needed := (limit + perPage - 1) / perPage; needed < last {
+			last = needed
+		}
+	}
+
+	var urls []string
+	for page := 2; page <= last; page++ {
+		query.Set("page", strconv.Itoa(page))
+		u.RawQuery = query.Encode()
+		urls = append(urls, u.String())
+	}
+	return urls
+}
+
+// lastPage returns the page number of lastURL, a rel="last" link, if it carries one
+func lastPage(lastURL string) (int, bool) {
+	u, err := url.Parse(lastURL)
+	if lastURL == "" || err != nil {
+		return 0, false
+	}
+	last, err := strconv.Atoi(u.Query().Get("page"))
+	return last, err == nil
+}
+
+// uniqueCommits drops repeated commits, which a listing shifting between the parallel page
+// requests, e.g. because of a push, can return twice
+func uniqueCommits(commits []Commit) []Commit {
+	seen := make(map[string]bool, len(commits))
+	unique := commits[:0]
+	for _, commit := range commits {
+		if commit.SHA != "" && seen[commit.SHA] {
+			continue
+		}
+		seen[commit.SHA] = true
+		unique = append(unique, commit)
+	}
+	return unique
+}
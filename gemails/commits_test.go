@@ -0,0 +1,323 @@
+package gemails
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// commitJSON renders a commit whose author and committer share the given identity
+func commitJSON(sha, name, email string) map[string]interface{} {
+	identity := map[string]interface{}{"name": name, "email": email, "date": "2023-04-05T06:07:08Z"}
+	return map[string]interface{}{
+		"sha":    sha,
+		"commit": map[string]interface{}{"author": identity, "committer": identity},
+	}
+}
+
+// pagedCommits serves commits in pages of perPage, linking each page to the next and the last
+func pagedCommits(t *testing.T, commits []map[string]interface{}, perPage int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		start, end := (page-1)*perPage, page*perPage
+		if end < len(commits) {
+			link := func(page int) string {
+				u := *r.URL
+				query := u.Query()
+				query.Set("page", strconv.Itoa(page))
+				u.RawQuery = query.Encode()
+				return fmt.Sprintf("http://%s%s", r.Host, u.RequestURI())
+			}
+			last := (len(commits) + perPage - 1) / perPage
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next", <%s>; rel="last"`, link(page+1), link(last)))
+		} else {
+			end = len(commits)
+		}
+		if err := json.NewEncoder(w).Encode(commits[start:end]); err != nil {
+			t.Errorf("encoding commits: %v", err)
+		}
+	}
+}
+
+func TestFetchCommitsFollowsPagination(t *testing.T) {
+	var commits []map[string]interface{}
+	for i := 0; i < 5; i++ {
+		commits = append(commits, commitJSON(fmt.Sprintf("sha%d", i), "Dev", "dev@example.com"))
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/repos/octo/project/commits", pagedCommits(t, commits, 2))
+	client := newTestClient(t, mux)
+
+	got, err := client.FetchCommits(context.Background(), "octo", "project")
+	if err != nil {
+		t.Fatalf("FetchCommits: %v", err)
+	}
+	if len(got) != len(commits) {
+		t.Fatalf("got %d commits, want %d", len(got), len(commits))
+	}
+	for i, commit := range got {
+		if want := fmt.Sprintf("sha%d", i); commit.SHA != want {
+			t.Errorf("commit %d SHA = %q, want %q", i, commit.SHA, want)
+		}
+	}
+	author := got[0].CommitData.Author
+	if author.Email != "dev@example.com" || author.Name != "Dev" || !author.Date.Equal(time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)) {
+		t.Errorf("author = %+v", author)
+	}
+}
+
+func TestFetchCommitsSerialPagination(t *testing.T) {
+	var commits []map[string]interface{}
+	for i := 0; i < 5; i++ {
+		commits = append(commits, commitJSON(fmt.Sprintf("sha%d", i), "Dev", "dev@example.com"))
+	}
+	client := newTestClient(t, pagedCommits(t, commits, 2))
+	client.PageConcurrency = 1
+
+	got, err := client.FetchCommits(context.Background(), "octo", "project")
+	if err != nil {
+		t.Fatalf("FetchCommits: %v", err)
+	}
+	if len(got) != len(commits) {
+		t.Errorf("got %d commits, want %d", len(got), len(commits))
+	}
+}
+
+func TestFetchCommitsParallelDropsRepeats(t *testing.T) {
+	// A push between the requests shifts the listing, so page 2 repeats the end of page 1
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=2>; rel="next", <http://%s%s?page=3>; rel="last"`, r.Host, r.URL.Path, r.Host, r.URL.Path))
+		}
+		shas := map[string][]string{"": {"c1", "c2"}, "2": {"c2", "c3"}, "3": {"c4"}}[page]
+		var commits []map[string]interface{}
+		for _, sha := range shas {
+			commits = append(commits, commitJSON(sha, "Dev", "dev@example.com"))
+		}
+		if err := json.NewEncoder(w).Encode(commits); err != nil {
+			t.Errorf("encoding commits: %v", err)
+		}
+	}))
+	client.PageConcurrency = 2
+
+	got, err := client.FetchCommits(context.Background(), "octo", "project")
+	if err != nil {
+		t.Fatalf("FetchCommits: %v", err)
+	}
+	var shas []string
+	for _, commit := range got {
+		shas = append(shas, commit.SHA)
+	}
+	if want := []string{"c1", "c2", "c3", "c4"}; !reflect.DeepEqual(shas, want) {
+		t.Errorf("SHAs = %v, want %v", shas, want)
+	}
+}
+
+func TestPageRange(t *testing.T) {
+	tests := []struct {
+		last    string
+		perPage int
+		limit   int
+		want    []string
+	}{
+		{"", 100, 0, nil},
+		{"https://api.github.com/x?per_page=2", 2, 0, nil},
+		{"https://api.github.com/x?page=3&per_page=2", 2, 0, []string{"https://api.github.com/x?page=2&per_page=2", "https://api.github.com/x?page=3&per_page=2"}},
+		{"https://api.github.com/x?page=9&per_page=2", 2, 3, []string{"https://api.github.com/x?page=2&per_page=2"}},
+	}
+	for _, tt := range tests {
+		if got := pageRange(tt.last, tt.perPage, tt.limit); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pageRange(%q, %d, %d) = %v, want %v", tt.last, tt.perPage, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestFetchCommitsSendsFilters(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if got := query.Get("sha"); got != "dev" {
+			t.Errorf("sha = %q, want %q", got, "dev")
+		}
+		if got := query.Get("since"); got != "2024-01-02T00:00:00Z" {
+			t.Errorf("since = %q, want %q", got, "2024-01-02T00:00:00Z")
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+	client.Branch = "dev"
+	client.Since = time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	if _, err := client.FetchCommits(context.Background(), "octo", "project"); err != nil {
+		t.Fatalf("FetchCommits: %v", err)
+	}
+}
+
+func TestFetchCommitsEmptyRepository(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Git Repository is empty."}`, http.StatusConflict)
+	}))
+
+	commits, err := client.FetchCommits(context.Background(), "octo", "empty")
+	if err != nil || len(commits) != 0 {
+		t.Fatalf("FetchCommits = %d commits, %v; want none and no error", len(commits), err)
+	}
+}
+
+func TestFetchCommitsMaxCommits(t *testing.T) {
+	var commits []map[string]interface{}
+	for i := 0; i < 6; i++ {
+		commits = append(commits, commitJSON(fmt.Sprintf("sha%d", i), "Dev", "dev@example.com"))
+	}
+	client := newTestClient(t, pagedCommits(t, commits, 2))
+	client.MaxCommits = 3
+
+	got, err := client.FetchCommits(context.Background(), "octo", "project")
+	if err != nil {
+		t.Fatalf("FetchCommits: %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("got %d commits, want 3", len(got))
+	}
+}
+
+func TestFetchCommitsMinCommits(t *testing.T) {
+	client := newTestClient(t, pagedCommits(t, []map[string]interface{}{commitJSON("sha0", "Dev", "dev@example.com")}, 100))
+	client.MinCommits = 2
+
+	if _, err := client.FetchCommits(context.Background(), "octo", "tiny"); !errors.Is(err, ErrTooFewCommits) {
+		t.Fatalf("FetchCommits error = %v, want ErrTooFewCommits", err)
+	}
+}
+
+func TestFetchCommitsUnavailable(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusUnavailableForLegalReasons} {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+
+		if _, err := client.FetchCommits(context.Background(), "octo", "gone"); !errors.Is(err, ErrUnavailable) {
+			t.Errorf("status %d: FetchCommits error = %v, want ErrUnavailable", status, err)
+		}
+	}
+}
+
+func TestFetchCommitsToleratesNullAndMalformedData(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"sha": "unlinked", "author": null, "committer": null,
+			 "commit": {"author": {"name": "Ann", "email": "ann@example.com", "date": "2023-04-05T06:07:08Z"},
+			            "committer": {"name": "Ann", "email": "ann@example.com", "date": "2023-04-05T06:07:08Z"}}},
+			{"sha": "no-author", "commit": {"author": null, "committer": {"name": "Bob", "email": "bob@example.org"}}},
+			{"sha": "bad-fields", "commit": {"author": {"name": 42, "email": "eve@example.net", "date": "yesterday"}, "committer": "?"}},
+			{"sha": "renamed", "commit": {"authored_by": {"email": "new@example.com"}}},
+			{"sha": 7, "commit": {}},
+			"not a commit"
+		]`)
+	}))
+
+	commits, err := client.FetchCommits(context.Background(), "octo", "project")
+	if err != nil {
+		t.Fatalf("FetchCommits: %v", err)
+	}
+	if len(commits) != 4 {
+		t.Fatalf("got %d commits, want the 4 that decode", len(commits))
+	}
+
+	if got := commits[0].CommitData.Author; got.Email != "ann@example.com" || got.Date.IsZero() {
+		t.Errorf("unlinked commit author = %+v, want its commit email and date", got)
+	}
+	if got := commits[1]; got.CommitData.Author != (Identity{}) || got.CommitData.Committer.Email != "bob@example.org" {
+		t.Errorf("null author commit = %+v, want an empty author and Bob as committer", got.CommitData)
+	}
+	if got := commits[2].CommitData.Author; got.Email != "eve@example.net" || got.Name != "" || !got.Date.IsZero() {
+		t.Errorf("malformed author = %+v, want only the email", got)
+	}
+	if got := commits[3].CommitData; got.Author != (Identity{}) || got.Committer != (Identity{}) {
+		t.Errorf("commit without the known fields = %+v, want empty identities", got)
+	}
+}
+
+func TestIdentityUnmarshalNull(t *testing.T) {
+	id := Identity{Name: "kept"}
+	if err := json.Unmarshal([]byte(`null`), &id); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if id.Name != "kept" {
+		t.Errorf("null overwrote the identity: %+v", id)
+	}
+}
+
+func TestFetchCommitsMinCommitsAcrossPages(t *testing.T) {
+	// Pages of 2 commits: 3 pages hold 5 or 6
+	tests := []struct {
+		commits  int
+		min      int
+		tooFew   bool
+		requests int64
+	}{
+		{5, 3, false, 3}, // the 2 full pages before the last prove 5 or more
+		{5, 5, false, 3},
+		{5, 7, true, 1},  // 3 pages hold at most 6
+		{5, 6, true, 2},  // the last page is fetched to count them
+		{6, 6, false, 4}, // counted from the last page, then fetched in full
+	}
+	for _, test := range tests {
+		var commits []map[string]interface{}
+		for i := 0; i < test.commits; i++ {
+			commits = append(commits, commitJSON(fmt.Sprintf("sha%d", i), "Dev", "dev@example.com"))
+		}
+		var requests int64
+		handler := pagedCommits(t, commits, 2)
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&requests, 1)
+			handler(w, r)
+		}))
+		client.MinCommits = test.min
+
+		got, err := client.FetchCommits(context.Background(), "octo", "busy")
+		if test.tooFew != errors.Is(err, ErrTooFewCommits) {
+			t.Errorf("min %d: FetchCommits error = %v, want too few: %v", test.min, err, test.tooFew)
+		}
+		if !test.tooFew && len(got) != test.commits {
+			t.Errorf("min %d: got %d commits, want %d", test.min, len(got), test.commits)
+		}
+		if n := atomic.LoadInt64(&requests); n != test.requests {
+			t.Errorf("min %d: %d requests, want %d", test.min, n, test.requests)
+		}
+	}
+}
+
+func TestFetchCommitsMinCommitsSerial(t *testing.T) {
+	var commits []map[string]interface{}
+	for i := 0; i < 3; i++ {
+		commits = append(commits, commitJSON(fmt.Sprintf("sha%d", i), "Dev", "dev@example.com"))
+	}
+	// Without a rel="last" link, the whole listing is followed before judging it
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		start, end := (page-1)*2, page*2
+		if end < len(commits) {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=%d>; rel="next"`, r.Host, r.URL.Path, page+1))
+		} else {
+			end = len(commits)
+		}
+		json.NewEncoder(w).Encode(commits[start:end])
+	}))
+	client.MinCommits = 4
+	if _, err := client.FetchCommits(context.Background(), "octo", "busy"); !errors.Is(err, ErrTooFewCommits) {
+		t.Errorf("FetchCommits error = %v, want ErrTooFewCommits", err)
+	}
+}
@@ -0,0 +1,53 @@
+package gemails
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Contributor is an account listed as a contributor of a repository
+type Contributor struct {
+	Login string `json:"login"`
+}
+
+// FetchContributors fetches the contributor accounts of a repository, following pagination
+func (c *Client) FetchContributors(ctx context.Context, userOrOrg, repo string) ([]Contributor, error) {
+	pageURL := fmt.Sprintf("%s/repos/%s/%s/contributors?per_page=100", c.BaseURL, userOrOrg, repo)
+
+	var contributors []Contributor
+	for page := 1; pageURL != ""; page++ {
+		c.debugf("Fetching contributors page %d for %s/%s", page, userOrOrg, repo)
+		response, next, err := c.get(ctx, pageURL)
+		if err != nil {
+			return contributors, err
+		}
+		// Empty repositories answer with 204 No Content
+		if len(response) == 0 {
+			break
+		}
+
+		var pageContributors []Contributor
+		if err := json.Unmarshal(response, &pageContributors); err != nil {
+			return contributors, fmt.Errorf("error unmarshaling contributors for %s/%s: %w", userOrOrg, repo, err)
+		}
+		contributors = append(contributors, pageContributors...)
+		pageURL = next
+	}
+	return contributors, nil
+}
+
+// FetchProfile returns the name and public email set on a user's profile; the email is
+// empty when the user keeps it private
+func (c *Client) FetchProfile(ctx context.Context, login string) (Identity, error) {
+	response, _, err := c.get(ctx, fmt.Sprintf("%s/users/%s", c.BaseURL, login))
+	if err != nil || len(response) == 0 {
+		return Identity{}, err
+	}
+
+	var profile Identity
+	if err := json.Unmarshal(response, &profile); err != nil {
+		return Identity{}, fmt.Errorf("error unmarshaling profile of %s: %w", login, err)
+	}
+	return profile, nil
+}
@@ -0,0 +1,57 @@
+package gemails
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// noreplyRegex matches both the legacy username@ and the numbered ID+username@ noreply forms
+var noreplyRegex = regexp.MustCompile(`(?i)^(\d+\+)?[^@]+@users\.noreply\.github\.com$`)
+
+// botRegex matches the local parts used by automation accounts: GitHub App bots such as
+// dependabot[bot], well-known tools, and names ending in -bot or _bot
+var botRegex = regexp.MustCompile(`(?i)(\[bot\]$|^(dependabot|dependabot-preview|github-actions|actions-user|action|renovate|renovate-bot|greenkeeper|snyk-bot|bot)$|[-_.]bot$)`)
+
+// noreplyIDRegex matches the numeric ID prefix of noreply local parts, as in 123+octocat
+var noreplyIDRegex = regexp.MustCompile(`^\d+\+`)
+
+// ExtractDomain extracts the domain from an email address
+func ExtractDomain(email string) string {
+	parts := strings.Split(email, "@")
+	if len(parts) > 1 {
+		return parts[1]
+	}
+	return ""
+}
+
+// NormalizeEmail trims surrounding whitespace and angle brackets, as in " <foo@bar.com> "
+func NormalizeEmail(email string) string {
+	email = strings.TrimSpace(email)
+	if strings.HasPrefix(email, "<") && strings.HasSuffix(email, ">") {
+		email = strings.TrimSpace(email[1 : len(email)-1])
+	}
+	return email
+}
+
+// IsValidEmail reports whether email is a syntactically valid bare address,
+// rejecting placeholders such as "none" found in some commit metadata
+func IsValidEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// IsBot reports whether the email belongs to an automation account such as dependabot,
+// github-actions or renovate, judged by its local part
+func IsBot(email string) bool {
+	local := email
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		local = email[:i]
+	}
+	return botRegex.MatchString(noreplyIDRegex.ReplaceAllString(local, ""))
+}
+
+// IsNoreply reports whether the email is a GitHub-generated noreply address
+func IsNoreply(email string) bool {
+	return noreplyRegex.MatchString(email)
+}
@@ -0,0 +1,80 @@
+package gemails
+
+import "testing"
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := map[string]string{
+		"dev@example.com":       "dev@example.com",
+		"  dev@example.com\n":   "dev@example.com",
+		"<dev@example.com>":     "dev@example.com",
+		" < dev@example.com > ": "dev@example.com",
+		"":                      "",
+	}
+	for in, want := range tests {
+		if got := NormalizeEmail(in); got != want {
+			t.Errorf("NormalizeEmail(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestIsValidEmail(t *testing.T) {
+	tests := map[string]bool{
+		"dev@example.com":           true,
+		"first.last+tag@sub.ex.org": true,
+		"none":                      false,
+		"dev@":                      false,
+		"Dev <dev@example.com>":     false,
+		"":                          false,
+	}
+	for email, want := range tests {
+		if got := IsValidEmail(email); got != want {
+			t.Errorf("IsValidEmail(%q) = %v, want %v", email, got, want)
+		}
+	}
+}
+
+func TestIsNoreply(t *testing.T) {
+	tests := map[string]bool{
+		"octo@users.noreply.github.com":        true,
+		"123456+octo@users.noreply.github.com": true,
+		"OCTO@Users.NoReply.GitHub.com":        true,
+		"noreply@github.com":                   false,
+		"octo@example.com":                     false,
+	}
+	for email, want := range tests {
+		if got := IsNoreply(email); got != want {
+			t.Errorf("IsNoreply(%q) = %v, want %v", email, got, want)
+		}
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	tests := map[string]bool{
+		"49699333+dependabot[bot]@users.noreply.github.com":     true,
+		"41898282+github-actions[bot]@users.noreply.github.com": true,
+		"github-actions@github.com":                             true,
+		"bot@renovateapp.com":                                   true,
+		"snyk-bot@snyk.io":                                      true,
+		"release_bot@example.com":                               true,
+		"123+octocat@users.noreply.github.com":                  false,
+		"abbot@example.com":                                     false,
+		"robotics@example.com":                                  false,
+	}
+	for email, want := range tests {
+		if got := IsBot(email); got != want {
+			t.Errorf("IsBot(%q) = %v, want %v", email, got, want)
+		}
+	}
+}
+
+func TestExtractDomain(t *testing.T) {
+	tests := map[string]string{
+		"dev@example.com": "example.com",
+		"none":            "",
+	}
+	for email, want := range tests {
+		if got := ExtractDomain(email); got != want {
+			t.Errorf("ExtractDomain(%q) = %q, want %q", email, got, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package gemails
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// ETagCache remembers response ETags and bodies by URL so that unchanged pages can be
+// revalidated with If-None-Match. GitHub does not count 304 responses against the rate limit.
+type ETagCache struct {
+	mu      sync.Mutex
+	entries map[string]etagEntry
+}
+
+// etagEntry is a cached response: its ETag, rel="next" and rel="last" links and body
+type etagEntry struct {
+	ETag string          `json:"etag"`
+	Next string          `json:"next,omitempty"`
+	Last string          `json:"last,omitempty"`
+	Body json.RawMessage `json:"body"`
+}
+
+// NewETagCache returns an empty ETagCache
+func NewETagCache() *ETagCache {
+	return &ETagCache{entries: make(map[string]etagEntry)}
+}
+
+// LoadETagCache reads a cache saved with Save; a missing file yields an empty cache
+func LoadETagCache(path string) (*ETagCache, error) {
+	cache := NewETagCache()
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// Save writes the cache to path
+func (e *ETagCache) Save(path string) error {
+	e.mu.Lock()
+	data, err := json.Marshal(e.entries)
+	e.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func (e *ETagCache) lookup(url string) (etagEntry, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	entry, ok := e.entries[url]
+	return entry, ok
+}
+
+func (e *ETagCache) store(url string, entry etagEntry) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.entries[url] = entry
+}
@@ -0,0 +1,69 @@
+package gemails
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Event represents an entry of a user's public activity feed
+type Event struct {
+	Type string `json:"type"`
+	Repo struct {
+		Name string `json:"name"`
+	} `json:"repo"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// pushPayload is the payload of a PushEvent
+type pushPayload struct {
+	Commits []struct {
+		SHA    string   `json:"sha"`
+		Author Identity `json:"author"`
+	} `json:"commits"`
+}
+
+// EventCommit is a commit author found in a push event, with the "owner/name" repository it was pushed to
+type EventCommit struct {
+	SHA    string
+	Author Identity
+	Repo   string
+}
+
+// FetchPushEventCommits fetches the public events of a user and returns the commit authors
+// of its push events. This covers activity in repositories the user does not own.
+func (c *Client) FetchPushEventCommits(ctx context.Context, user string) ([]EventCommit, error) {
+	pageURL := fmt.Sprintf("%s/users/%s/events/public?per_page=100", c.BaseURL, user)
+
+	var commits []EventCommit
+	for page := 1; pageURL != ""; page++ {
+		c.debugf("Fetching public events page %d for %s", page, user)
+		response, next, err := c.get(ctx, pageURL)
+		if err != nil {
+			return commits, err
+		}
+		if len(response) == 0 {
+			break
+		}
+
+		var events []Event
+		if err := json.Unmarshal(response, &events); err != nil {
+			return commits, fmt.Errorf("error unmarshaling events for %s: %w", user, err)
+		}
+		for _, event := range events {
+			if event.Type != "PushEvent" {
+				continue
+			}
+			var payload pushPayload
+			if err := json.Unmarshal(event.Payload, &payload); err != nil {
+				c.logf("Error unmarshaling push event payload for %s: %v", event.Repo.Name, err)
+				continue
+			}
+			for _, commit := range payload.Commits {
+				commits = append(commits, EventCommit{SHA: commit.SHA, Author: commit.Author, Repo: event.Repo.Name})
+			}
+		}
+		pageURL = next
+	}
+	return commits, nil
+}
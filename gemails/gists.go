@@ -0,0 +1,122 @@
+package gemails
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Gist is a gist owned by a user
+type Gist struct {
+	ID string `json:"id"`
+}
+
+// GistCommit is a revision of a gist. Unlike repository commits, the API records the
+// account that committed it rather than a git author and committer with their emails.
+type GistCommit struct {
+	Version     string    `json:"version"`
+	User        *Owner    `json:"user"`
+	CommittedAt time.Time `json:"committed_at"`
+}
+
+// FetchGists fetches the public gists of a user, following pagination
+func (c *Client) FetchGists(ctx context.Context, user string) ([]Gist, error) {
+	pageURL := fmt.Sprintf("%s/users/%s/gists?per_page=100", c.BaseURL, user)
+
+	var gists []Gist
+	for page := 1; pageURL != ""; page++ {
+		c.debugf("Fetching gists page %d for %s", page, user)
+		response, next, err := c.get(ctx, pageURL)
+		if err != nil {
+			return gists, err
+		}
+		if len(response) == 0 {
+			break
+		}
+
+		var pageGists []Gist
+		if err := json.Unmarshal(response, &pageGists); err != nil {
+			return gists, fmt.Errorf("error unmarshaling gists for %s: %w", user, err)
+		}
+		gists = append(gists, pageGists...)
+		pageURL = next
+	}
+	return gists, nil
+}
+
+// FetchGistCommits fetches the revisions of a gist, following pagination.
+// On error it returns the revisions gathered so far alongside the error; a gist that
+// was deleted in the meantime yields an error wrapping ErrUnavailable.
+func (c *Client) FetchGistCommits(ctx context.Context, id string) ([]GistCommit, error) {
+	pageURL := fmt.Sprintf("%s/gists/%s/commits?per_page=100", c.BaseURL, id)
+
+	var commits []GistCommit
+	for page := 1; pageURL != ""; page++ {
+		c.debugf("Fetching commits page %d for gist %s", page, id)
+		response, next, err := c.get(ctx, pageURL)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return commits, fmt.Errorf("%w: gist %s was not found", ErrUnavailable, id)
+		} else if err != nil {
+			return commits, err
+		}
+		if len(response) == 0 {
+			break
+		}
+
+		var pageCommits []GistCommit
+		if err := json.Unmarshal(response, &pageCommits); err != nil {
+			return commits, fmt.Errorf("error unmarshaling commits for gist %s: %w", id, err)
+		}
+		commits = append(commits, pageCommits...)
+		pageURL = next
+	}
+	return commits, nil
+}
+
+// collectGists adds the public profile emails of the accounts that committed to the gists
+// of user to col, dated by their revisions. Gists are sourced as "gist:<id>".
+func (c *Client) collectGists(ctx context.Context, col *collector, user string) {
+	gists, err := c.FetchGists(ctx, user)
+	if err != nil {
+		c.logf("Error fetching gists for %s: %v", user, err)
+		col.addError(fmt.Errorf("gists of %s: %w", user, err))
+	}
+	for _, gist := range gists {
+		if ctx.Err() != nil {
+			return
+		}
+		source := "gist:" + gist.ID
+		commits, err := c.FetchGistCommits(ctx, gist.ID)
+		switch {
+		case errors.Is(err, ErrUnavailable):
+			c.logf("Skipping gist: %v", err)
+		case err != nil:
+			c.logf("Error fetching commits for gist %s: %v", gist.ID, err)
+			col.addError(fmt.Errorf("commits of %s: %w", source, err))
+		}
+
+		for _, commit := range commits {
+			if commit.User == nil || commit.User.Login == "" {
+				continue
+			}
+			identity, ok := col.profile(commit.User.Login)
+			if !ok {
+				var profileErr error
+				if identity, profileErr = c.FetchProfile(ctx, commit.User.Login); profileErr != nil {
+					c.logf("Error fetching profile of %s: %v", commit.User.Login, profileErr)
+					col.addError(fmt.Errorf("profile of %s: %w", commit.User.Login, profileErr))
+					continue
+				}
+				col.storeProfile(commit.User.Login, identity)
+			}
+			identity.Date = commit.CommittedAt
+			if col.add(identity, source, commit.Version) {
+				col.countCommits(NormalizeEmail(identity.Email), 1)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package gemails
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CompilePattern compiles a repository name pattern. A pattern wrapped in slashes, such as
+// /^docs-/, is a regular expression; anything else is a glob where * matches any run of
+// characters and ? a single one. Matching ignores case, as GitHub names do.
+func CompilePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile("(?i)" + pattern[1:len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		return re, nil
+	}
+
+	var expr strings.Builder
+	expr.WriteString("(?i)^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// CompileEmailPattern compiles a regular expression matched against email addresses, such as
+// @example\.com$ to keep a single organization's. Matching ignores case, as domains do.
+func CompileEmailPattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// matchesAny reports whether name matches one of the patterns
+func matchesAny(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package gemails
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// RateLimit is the core REST API quota of the token
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// FetchRateLimit reports the token's remaining core quota. Querying /rate_limit does not
+// count against the quota; the response bypasses the ETag cache so it is never stale.
+func (c *Client) FetchRateLimit(ctx context.Context) (*RateLimit, error) {
+	url := c.BaseURL + "/rate_limit"
+	resp, err := c.do(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode, URL: url}
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	var limits struct {
+		Resources struct {
+			Core struct {
+				Limit     int   `json:"limit"`
+				Remaining int   `json:"remaining"`
+				Reset     int64 `json:"reset"`
+			} `json:"core"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(body, &limits); err != nil {
+		return nil, fmt.Errorf("error unmarshaling rate limit: %w", err)
+	}
+	core := limits.Resources.Core
+	return &RateLimit{Limit: core.Limit, Remaining: core.Remaining, Reset: time.Unix(core.Reset, 0)}, nil
+}
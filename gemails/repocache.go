@@ -0,0 +1,74 @@
+package gemails
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// RepoCache remembers the full repository listing of each account so that repeated scans
+// of the same accounts skip listing them again while the listings are fresh
+type RepoCache struct {
+	// MaxAge is how long a cached listing is used; listings older than that, or all of them
+	// when it is 0, are fetched again and replace the cached ones
+	MaxAge time.Duration
+
+	mu      sync.Mutex
+	entries map[string]repoCacheEntry
+}
+
+// repoCacheEntry is a cached listing and when it was fetched
+type repoCacheEntry struct {
+	Fetched      time.Time    `json:"fetched"`
+	Repositories []Repository `json:"repositories"`
+}
+
+// NewRepoCache returns an empty RepoCache whose listings stay fresh for maxAge
+func NewRepoCache(maxAge time.Duration) *RepoCache {
+	return &RepoCache{MaxAge: maxAge, entries: make(map[string]repoCacheEntry)}
+}
+
+// LoadRepoCache reads a cache saved with Save; a missing file yields an empty cache
+func LoadRepoCache(path string, maxAge time.Duration) (*RepoCache, error) {
+	cache := NewRepoCache(maxAge)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// Save writes the cache to path
+func (r *RepoCache) Save(path string) error {
+	r.mu.Lock()
+	data, err := json.Marshal(r.entries)
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// lookup returns the listing cached under key if it is still fresh, and when it was fetched
+func (r *RepoCache) lookup(key string) ([]Repository, time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[key]
+	if !ok || time.Since(entry.Fetched) >= r.MaxAge {
+		return nil, time.Time{}, false
+	}
+	return entry.Repositories, entry.Fetched, true
+}
+
+func (r *RepoCache) store(key string, repos []Repository) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[key] = repoCacheEntry{Fetched: time.Now(), Repositories: repos}
+}
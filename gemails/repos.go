@@ -0,0 +1,185 @@
+package gemails
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Repository represents a GitHub repository
+type Repository struct {
+	Name     string `json:"name"`
+	Fork     bool   `json:"fork"`
+	Archived bool   `json:"archived"`
+	Owner    Owner  `json:"owner"`
+}
+
+// Owner is the account a repository belongs to
+type Owner struct {
+	Login string `json:"login"`
+}
+
+// OwnerLogin returns the login of the repository's owner, or fallback when the
+// repository did not come from a listing (e.g. a repository named on the command line)
+func (r Repository) OwnerLogin(fallback string) string {
+	if r.Owner.Login != "" {
+		return r.Owner.Login
+	}
+	return fallback
+}
+
+// FetchAccountType looks up whether the account is a "User" or an "Organization"
+func (c *Client) FetchAccountType(ctx context.Context, userOrOrg string) (string, error) {
+	url := fmt.Sprintf("%s/users/%s", c.BaseURL, userOrOrg)
+	response, _, err := c.get(ctx, url)
+	if err != nil {
+		return "", err
+	}
+
+	var account struct {
+		Type string `json:"type"`
+	}
+	if len(response) == 0 {
+		return "", nil
+	}
+	if err := json.Unmarshal(response, &account); err != nil {
+		return "", fmt.Errorf("error unmarshaling account %s: %w", userOrOrg, err)
+	}
+	return account.Type, nil
+}
+
+// FetchAuthenticatedUser returns the login of the account the token belongs to
+func (c *Client) FetchAuthenticatedUser(ctx context.Context) (string, error) {
+	response, _, err := c.get(ctx, c.BaseURL+"/user")
+	if err != nil {
+		return "", err
+	}
+
+	var account struct {
+		Login string `json:"login"`
+	}
+	if err := json.Unmarshal(response, &account); err != nil {
+		return "", fmt.Errorf("error unmarshaling authenticated user: %w", err)
+	}
+	return account.Login, nil
+}
+
+// FetchRepository looks up a single repository of userOrOrg. Renamed or transferred
+// repositories are followed to their new location, which the returned Repository carries;
+// ones that cannot be found yield an error wrapping ErrUnavailable.
+func (c *Client) FetchRepository(ctx context.Context, userOrOrg, name string) (Repository, error) {
+	// The HTTP client follows GitHub's 301 and 302 redirects for moved repositories
+	response, _, err := c.get(ctx, fmt.Sprintf("%s/repos/%s/%s", c.BaseURL, userOrOrg, name))
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusUnavailableForLegalReasons) {
+		return Repository{}, fmt.Errorf("%w: %s/%s was not found or the token cannot access it", ErrUnavailable, userOrOrg, name)
+	}
+	if err != nil {
+		return Repository{}, err
+	}
+
+	var repo Repository
+	if err := json.Unmarshal(response, &repo); err != nil {
+		return Repository{}, fmt.Errorf("error unmarshaling repository %s/%s: %w", userOrOrg, name, err)
+	}
+	if !strings.EqualFold(repo.OwnerLogin(userOrOrg)+"/"+repo.Name, userOrOrg+"/"+name) {
+		c.logf("Warning: repository %s/%s has moved to %s/%s", userOrOrg, name, repo.OwnerLogin(userOrOrg), repo.Name)
+	}
+	return repo, nil
+}
+
+// FetchRepos fetches all repositories for a user or organization, following pagination.
+// With IncludePrivate, private repositories visible to the token are listed as well.
+func (c *Client) FetchRepos(ctx context.Context, userOrOrg string) ([]Repository, error) {
+	url, err := c.reposURL(ctx, userOrOrg)
+	if err != nil {
+		return nil, err
+	}
+	return c.listRepos(ctx, url, userOrOrg, nil, 0)
+}
+
+// FetchStarredRepos fetches the repositories a user has starred, following pagination
+func (c *Client) FetchStarredRepos(ctx context.Context, user string) ([]Repository, error) {
+	return c.listRepos(ctx, c.starredURL(user), user, nil, 0)
+}
+
+// starredURL is the listing endpoint of the repositories user has starred
+func (c *Client) starredURL(user string) string {
+	return fmt.Sprintf("%s/users/%s/starred?per_page=100", c.BaseURL, user)
+}
+
+// listRepos lists the repositories at url, a listing of userOrOrg, that keep accepts
+// (all when it is nil), stopping early once limit of them are found; 0 means no limit
+func (c *Client) listRepos(ctx context.Context, url, userOrOrg string, keep func(Repository) bool, limit int) ([]Repository, error) {
+	var repos []Repository
+	seen := make(map[string]bool)
+	for page := 1; url != ""; page++ {
+		c.debugf("Fetching repositories page %d for %s", page, userOrOrg)
+		response, next, err := c.get(ctx, url)
+		if err != nil {
+			return repos, err
+		}
+
+		if len(response) == 0 {
+			break
+		}
+
+		var pageRepos []Repository
+		if err := json.Unmarshal(response, &pageRepos); err != nil {
+			return repos, fmt.Errorf("error unmarshaling repositories: %w", err)
+		}
+		if len(pageRepos) == 0 {
+			break
+		}
+
+		// Deduplicate in case the listing shifts between pages; starred
+		// repositories of different owners can share a name
+		for _, repo := range pageRepos {
+			fullName := repo.OwnerLogin(userOrOrg) + "/" + repo.Name
+			if seen[fullName] {
+				continue
+			}
+			seen[fullName] = true
+			if keep != nil && !keep(repo) {
+				continue
+			}
+			repos = append(repos, repo)
+			if limit > 0 && len(repos) == limit {
+				c.debugf("Reached the limit of %d repositories for %s", limit, userOrOrg)
+				return repos, nil
+			}
+		}
+		url = next
+	}
+	return repos, nil
+}
+
+// reposURL picks the listing endpoint for userOrOrg. Organizations have their own endpoint
+// that also lists private/internal repos visible to the token; a user's private repos are
+// only listed by the authenticated /user/repos endpoint, when the token belongs to that user.
+func (c *Client) reposURL(ctx context.Context, userOrOrg string) (string, error) {
+	accountType, err := c.FetchAccountType(ctx, userOrOrg)
+	if err != nil {
+		c.logf("Warning: could not look up account type for %s, assuming a user: %v", userOrOrg, err)
+	} else if accountType == "Organization" {
+		if c.IncludePrivate {
+			return fmt.Sprintf("%s/orgs/%s/repos?type=all&per_page=100", c.BaseURL, userOrOrg), nil
+		}
+		return fmt.Sprintf("%s/orgs/%s/repos?per_page=100", c.BaseURL, userOrOrg), nil
+	}
+
+	if c.IncludePrivate {
+		login, err := c.FetchAuthenticatedUser(ctx)
+		if err != nil {
+			return "", fmt.Errorf("error looking up the token's user: %w", err)
+		}
+		if strings.EqualFold(login, userOrOrg) {
+			return fmt.Sprintf("%s/user/repos?affiliation=owner&visibility=all&per_page=100", c.BaseURL), nil
+		}
+		c.logf("Warning: the token belongs to %s, so private repositories of %s cannot be listed", login, userOrOrg)
+	}
+	return fmt.Sprintf("%s/users/%s/repos?per_page=100", c.BaseURL, userOrOrg), nil
+}
@@ -0,0 +1,83 @@
+package gemails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchRepositoryFollowsRename(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/octo/old-name", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/repositories/42", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/repositories/42", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "new-name", "owner": {"login": "octo-org"}}`)
+	})
+	client := newTestClient(t, mux)
+
+	repo, err := client.FetchRepository(context.Background(), "octo", "old-name")
+	if err != nil {
+		t.Fatalf("FetchRepository: %v", err)
+	}
+	if repo.Name != "new-name" || repo.OwnerLogin("octo") != "octo-org" {
+		t.Errorf("FetchRepository = %s/%s, want octo-org/new-name", repo.OwnerLogin("octo"), repo.Name)
+	}
+}
+
+func TestFetchRepositoryNotFound(t *testing.T) {
+	client := newTestClient(t, http.NotFoundHandler())
+
+	if _, err := client.FetchRepository(context.Background(), "octo", "typo"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("FetchRepository error = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestCollectEmailsRepoCache(t *testing.T) {
+	var listings int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/octo", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"login": "octo", "type": "User"}`)
+	})
+	mux.HandleFunc("/users/octo/repos", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&listings, 1)
+		fmt.Fprint(w, `[{"name": "alpha", "owner": {"login": "octo"}}, {"name": "beta", "fork": true, "owner": {"login": "octo"}}]`)
+	})
+	mux.HandleFunc("/repos/octo/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	client := newTestClient(t, mux)
+	client.RepoCache = NewRepoCache(time.Hour)
+
+	if _, err := client.CollectEmails(context.Background(), "octo"); err != nil {
+		t.Fatalf("CollectEmails: %v", err)
+	}
+	// The cached listing is unfiltered, so a filter added afterwards still applies
+	client.SkipForks = true
+	result, err := client.CollectEmails(context.Background(), "octo")
+	if err != nil {
+		t.Fatalf("CollectEmails: %v", err)
+	}
+	if listings != 1 || result.Repositories != 1 {
+		t.Errorf("listings, Repositories = %d, %d; want 1, 1", listings, result.Repositories)
+	}
+
+	path := filepath.Join(t.TempDir(), "repos.json")
+	if err := client.RepoCache.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if client.RepoCache, err = LoadRepoCache(path, 0); err != nil {
+		t.Fatalf("LoadRepoCache: %v", err)
+	}
+	if _, err := client.CollectEmails(context.Background(), "octo"); err != nil {
+		t.Fatalf("CollectEmails: %v", err)
+	}
+	if listings != 2 {
+		t.Errorf("listings = %d, want 2 once the cached listing is stale", listings)
+	}
+}
@@ -1,209 +1,1134 @@
-package main
-
-import (
-	"encoding/json"
-	"flag"
-	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
-	"os"
-	"regexp"
-	"strings"
-	"time"
-
-	"github.com/likexian/whois"
-	"github.com/fatih/color"
-)
-
-const githubAPI = "https://api.github.com"
-
-// Repository represents a GitHub repository
-type Repository struct {
-	Name string `json:"name"`
-}
-
-// Commit represents a GitHub commit
-type Commit struct {
-	CommitData struct {
-		Committer struct {
-			Email string `json:"email"`
-		} `json:"committer"`
-	} `json:"commit"`
-}
-
-func main() {
-	// Define and parse command-line flags
-	username := flag.String("u", "", "GitHub username or organization")
-	token := flag.String("t", "", "GitHub API token")
-	outputFile := flag.String("o", "emails.txt", "Output file to save unique emails")
-	repo := flag.String("r", "", "Specific repository to process (leave empty to process all repositories)")
-	flag.Parse()
-
-	// Validate inputs
-	if *username == "" || *token == "" {
-		log.Fatalf("Usage: gemails -u <username> -t <token> -o <output file> [-r <repo>]")
-	}
-
-	// Track unique emails using a map
-	uniqueEmails := make(map[string]bool)
-	uniqueDomains := make(map[string]bool)
-
-	var repos []Repository
-	if *repo != "" {
-		// Process only the specific repository
-		repos = append(repos, Repository{Name: *repo})
-	} else {
-		// Fetch all repositories
-		repos = fetchRepos(*username, *token)
-	}
-
-	// Process each repository
-	for _, repo := range repos {
-		fmt.Printf("Processing repository: %s\n", repo.Name)
-		// Fetch commits for each repository
-		commits := fetchCommits(*username, repo.Name, *token)
-		for _, commit := range commits {
-			email := commit.CommitData.Committer.Email
-			if email != "" && !uniqueEmails[email] {
-				uniqueEmails[email] = true
-				// Extract domain and add it to uniqueDomains map
-				domain := extractDomainFromEmail(email)
-				if domain != "" {
-					uniqueDomains[domain] = true
-				}
-			}
-		}
-	}
-
-	// Save unique emails to the specified output file
-	saveUniqueEmails(uniqueEmails, *outputFile)
-	fmt.Printf("\nUnique emails saved to %s\n", *outputFile)
-
-	// Now, check the domain expiry for each unique domain
-	checkDomainsExpiry(uniqueDomains)
-}
-
-// fetchRepos fetches all repositories for a user or organization
-func fetchRepos(userOrOrg, token string) []Repository {
-	url := fmt.Sprintf("%s/users/%s/repos", githubAPI, userOrOrg)
-	response := sendRequest(url, token)
-
-	var repos []Repository
-	if err := json.Unmarshal(response, &repos); err != nil {
-		log.Fatalf("Error unmarshaling repositories: %v", err)
-	}
-	return repos
-}
-
-// fetchCommits fetches all commits for a given repository
-func fetchCommits(userOrOrg, repo, token string) []Commit {
-	url := fmt.Sprintf("%s/repos/%s/%s/commits", githubAPI, userOrOrg, repo)
-	response := sendRequest(url, token)
-
-	var commits []Commit
-	if err := json.Unmarshal(response, &commits); err != nil {
-		log.Printf("Error unmarshaling commits for repo %s: %v", repo, err)
-	}
-	return commits
-}
-
-// sendRequest sends an HTTP GET request to the provided URL with the GitHub token
-func sendRequest(url, token string) []byte {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Fatalf("Error creating request: %v", err)
-	}
-
-	req.Header.Add("Authorization", "Bearer "+token)
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Fatalf("Error sending request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Handle different HTTP status codes, especially 409 Conflict
-	if resp.StatusCode == http.StatusConflict { // 409 Conflict
-		log.Printf("Warning: 409 Conflict encountered for URL: %s. Skipping.", url)
-		return nil // Skip this request and return an empty response
-	} else if resp.StatusCode != http.StatusOK {
-		log.Fatalf("GitHub API returned status code %d for URL %s", resp.StatusCode, url)
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatalf("Error reading response body: %v", err)
-	}
-	return body
-}
-
-// saveUniqueEmails saves unique emails to a specified file
-func saveUniqueEmails(emails map[string]bool, outputFile string) {
-	file, err := os.Create(outputFile)
-	if err != nil {
-		log.Fatalf("Error creating output file: %v", err)
-	}
-	defer file.Close()
-
-	for email := range emails {
-		if _, err := file.WriteString(email + "\n"); err != nil {
-			log.Fatalf("Error writing to output file: %v", err)
-		}
-	}
-}
-
-// checkDomainsExpiry checks WHOIS info for each domain and compares expiry date
-func checkDomainsExpiry(domains map[string]bool) {
-	for domain := range domains {
-		// Perform WHOIS lookup
-		whoisInfo, err := whois.Whois(domain)
-		if err != nil {
-			log.Printf("Error fetching WHOIS info for domain %s: %v", domain, err)
-			continue
-		}
-
-		// Try to find the expiry date in the WHOIS info (simplified)
-		expiryDate := extractExpiryDateFromWhois(whoisInfo)
-		if expiryDate.IsZero() {
-			log.Printf("No expiry date found for domain %s", domain)
-			continue
-		}
-
-		// Compare the expiry date with today's date
-		daysUntilExpiry := time.Until(expiryDate).Hours() / 24
-		if daysUntilExpiry < 30 {
-			color.Red("Domain %s is nearing expiry (Expires on %s, %d days left)", domain, expiryDate.Format("2006-01-02"), int(daysUntilExpiry))
-		} else {
-			color.Green("Domain %s has a valid expiry date (Expires on %s, %d days left)", domain, expiryDate.Format("2006-01-02"), int(daysUntilExpiry))
-		}
-	}
-}
-
-// extractDomainFromEmail extracts the domain from an email address
-func extractDomainFromEmail(email string) string {
-	parts := strings.Split(email, "@")
-	if len(parts) > 1 {
-		return parts[1]
-	}
-	return ""
-}
-
-// extractExpiryDateFromWhois extracts the expiry date from the WHOIS information
-func extractExpiryDateFromWhois(whoisInfo string) time.Time {
-	// Simple regex pattern to match expiry date (in ISO 8601 format or similar)
-	expiryRegex := regexp.MustCompile(`(?i)(?:(expiration|expire|expiry)[^\w]*(date|time)[^\w]*[:\s]+)(\d{4}-\d{2}-\d{2})`)
-	matches := expiryRegex.FindStringSubmatch(whoisInfo)
-
-	if len(matches) > 3 {
-		expiryDateStr := matches[3]
-		expiryDate, err := time.Parse("2006-01-02", expiryDateStr)
-		if err != nil {
-			log.Printf("Error parsing expiry date: %v", err)
-			return time.Time{}
-		}
-		return expiryDate
-	}
-
-	return time.Time{} // return zero value if no expiry date is found
-}
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mux0x/gemails/gemails"
+)
+
+// EmailRecord is the JSON representation of a collected email and where it was seen
+type EmailRecord struct {
+	Email        string   `json:"email"`
+	Names        []string `json:"names,omitempty"`
+	Domain       string   `json:"domain"`
+	DomainType   string   `json:"domainType"`
+	Repositories []string `json:"repositories"`
+	// FoundIn is the repository that first yielded the email
+	FoundIn string `json:"foundIn"`
+	// Commits is the number of commits the email authored or committed
+	Commits int `json:"commits"`
+	// FirstSeen and LastSeen are the earliest and latest commits the email was recorded in
+	FirstSeen *gemails.Sighting `json:"firstSeen,omitempty"`
+	LastSeen  *gemails.Sighting `json:"lastSeen,omitempty"`
+}
+
+// exitExpiring is the exit status of -fail-if-expiring, distinct from the 1 of other failures
+const exitExpiring = 2
+
+// exitIncomplete is the exit status of -strict when a run had non-fatal failures
+const exitIncomplete = 3
+
+// lowQuota is the remaining request count below which a scan is likely to stall
+const lowQuota = 100
+
+// statusOut receives progress messages; it switches to stderr when emails are written to stdout
+var statusOut io.Writer = os.Stdout
+
+// listFlag collects the values of a flag that may be repeated or given as a comma-separated list
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, splitList(value)...)
+	return nil
+}
+
+// mapFlag collects key=value pairs given by repeating a flag or separating pairs with commas
+type mapFlag map[string]string
+
+func (m mapFlag) String() string {
+	pairs := make([]string, 0, len(m))
+	for key, value := range m {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m mapFlag) Set(value string) error {
+	for _, pair := range splitList(value) {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return fmt.Errorf("expected key=value, got %q", pair)
+		}
+		m[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+	return nil
+}
+
+// runScan runs the collect command, or with checkExpiry the report command: it scans the
+// GitHub accounts for emails, saves them and, for report, checks the expiry of their domains
+func runScan(name string, args []string, checkExpiry bool) {
+	// Define and parse command-line flags
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gemails [%s] -u <username> -t <token> [flags]\n(run \"gemails help\" for the other commands)\n\nFlags:\n", name)
+		fs.PrintDefaults()
+	}
+	var usernames listFlag
+	fs.Var(&usernames, "u", "GitHub username or organization (repeatable or comma-separated)")
+	usernamesFile := fs.String("u-file", "", "File with one GitHub username or organization per line")
+	token := fs.String("t", "", "GitHub API token (defaults to $GITHUB_TOKEN or $GH_TOKEN)")
+	tokenFile := fs.String("t-file", "", "File containing the GitHub API token, e.g. one mounted by a secret manager")
+	appID := fs.Int64("app-id", 0, "GitHub App ID, to authenticate as an app installation instead of with -t")
+	appInstallationID := fs.Int64("app-installation-id", 0, "Installation ID of the GitHub App")
+	appKeyPath := fs.String("app-key", "", "Path to the GitHub App's PEM private key")
+	apiURL := fs.String("api-url", gemails.DefaultBaseURL, "GitHub API root, e.g. https://github.example.com/api/v3 for GitHub Enterprise")
+	var outputFiles listFlag
+	fs.Var(&outputFiles, "o", "Output file to save unique emails, repeatable or comma-separated; the format follows the .txt, .json or .csv extension (\"-\" for stdout, default emails.txt)")
+	format := fs.String("format", "text", "Output format for a single -o: text, json or csv (defaults to the file extension)")
+	dbPath := fs.String("db", "", "SQLite database to upsert the emails and domains of every run into, for querying across scans")
+	domainsOut := fs.String("domains-out", "", "Also write the sorted unique domains to this file, one per line")
+	withCounts := fs.Bool("with-counts", false, "Write each email's commit count in text output and print the most active committers")
+	withNames := fs.Bool("names", false, "Include the commit author/committer names of each email in text and JSON output")
+	noSort := fs.Bool("no-sort", false, "Write text output without sorting it")
+	groupByDomain := fs.Bool("group-by-domain", false, "Write text output as sections of emails grouped under their domain")
+	streamOutput := fs.Bool("stream", false, "Write each new email as a JSON line as soon as it is found instead of at the end")
+	appendOutput := fs.Bool("append", false, "Append new emails to the output file instead of overwriting it (text format only)")
+	repo := fs.String("r", "", "Specific repository to process (leave empty to process all repositories)")
+	repoOwner := fs.String("owner", "", "Owner of the repositories whose commits are fetched, when it differs from the -u account listing them (defaults to the listed owner)")
+	starred := fs.Bool("starred", false, "Process the repositories each account has starred instead of the ones it owns")
+	includePrivate := fs.Bool("include-private", false, "Also list private repositories visible to the token (requires the repo scope)")
+	var includeRepos, excludeRepos listFlag
+	fs.Var(&includeRepos, "include", "Only process repositories whose name matches this glob or /regex/ (repeatable or comma-separated)")
+	fs.Var(&excludeRepos, "exclude", "Skip repositories whose name matches this glob or /regex/ (repeatable or comma-separated)")
+	noForks := fs.Bool("no-forks", false, "Skip forked repositories")
+	noArchived := fs.Bool("no-archived", false, "Skip archived repositories")
+	maxRepos := fs.Int("max-repos", 0, "Process at most this many repositories per account, after filtering (0 means unlimited)")
+	minCommits := fs.Int("min-commits", 0, "Skip repositories with fewer commits than this (0 keeps all)")
+	maxCommits := fs.Int("max-commits", 0, "Stop after this many commits per repository (0 means unlimited)")
+	since := fs.String("since", "", "Only fetch commits after this date (RFC3339 or YYYY-MM-DD)")
+	branch := fs.String("branch", "", "Fetch commits from this branch instead of the default branch")
+	pullRequests := fs.Bool("prs", false, "Also collect emails from pull request commits (extra API calls)")
+	contributors := fs.Bool("contributors", false, "Also collect the public profile emails of each repository's contributors (one API call per contributor)")
+	events := fs.Bool("events", false, "Also collect commit authors from the account's public push events")
+	gists := fs.Bool("gists", false, "Also collect the profile emails of the accounts committing to each user's public gists (extra API calls)")
+	includeNoreply := fs.Bool("include-noreply", false, "Keep GitHub noreply addresses (users.noreply.github.com)")
+	includeBots := fs.Bool("include-bots", false, "Keep the addresses of bots such as dependabot, github-actions and renovate")
+	var emailInclude, emailExclude listFlag
+	fs.Var(&emailInclude, "email-include", "Only keep emails matching this regular expression, e.g. @example\\.com$ (repeatable or comma-separated)")
+	fs.Var(&emailExclude, "email-exclude", "Drop emails matching this regular expression (repeatable or comma-separated)")
+	concurrency := fs.Int("c", 5, "Number of repositories to process concurrently")
+	pageConcurrency := fs.Int("page-concurrency", 4, "Number of commit pages of a repository to fetch in parallel once the last page is known (1 fetches them one by one)")
+	delay := fs.Duration("delay", 0, "Fixed pause between GitHub API requests, across all workers, e.g. 500ms (0 means none)")
+	maxWait := fs.Duration("max-wait", time.Hour, "Maximum time to sleep when rate limited before retrying")
+	// collect runs no WHOIS checks, so it does not define their flags and rejects them as unknown
+	var whois *whoisOptions
+	noWhois := !checkExpiry
+	var whoisSkip, whoisProxy string
+	var whoisOnly listFlag
+	if checkExpiry {
+		whois = addWhoisFlags(fs)
+		fs.BoolVar(&noWhois, "no-whois", false, "Skip the domain expiry checks and only collect emails and domains")
+		fs.StringVar(&whoisSkip, "whois-skip", "", "Comma-separated domains to exclude from WHOIS checks, in addition to common free-mail providers")
+		fs.Var(&whoisOnly, "whois-only", "Only run WHOIS checks on these domains and their subdomains, or on the domains listed in @file (repeatable or comma-separated)")
+		fs.StringVar(&whoisProxy, "proxy-whois", "", "SOCKS proxy for WHOIS lookups only, e.g. socks5://127.0.0.1:1080 (defaults to -proxy)")
+	}
+	freeDomainsPath := fs.String("free-domains", "", "File of free-mail domains, one per line, replacing the built-in list used to tag domains and skip WHOIS checks")
+	fs.BoolVar(&quiet, "quiet", false, "Only print warnings, errors and results, not progress lines")
+	noColor := fs.Bool("no-color", false, "Disable colored output, which is also off when not writing to a terminal or when NO_COLOR is set")
+	verbose := fs.Bool("v", false, "Verbose logging of requests, pagination and per-repository counts")
+	checkMX := fs.Bool("check-mx", false, "Check that each domain has MX records and flag those that cannot receive mail")
+	dryRun := fs.Bool("dry-run", false, "Collect and count emails without writing the output file or running WHOIS checks")
+	checkpointPath := fs.String("checkpoint", "", "File recording processed repositories so an interrupted scan can be resumed with -resume")
+	resume := fs.Bool("resume", false, "Resume from the -checkpoint file, skipping the repositories it lists as processed")
+	etagCachePath := fs.String("etag-cache", "", "File storing response ETags so unchanged pages are revalidated without using quota")
+	repoCachePath := fs.String("repo-cache", "", "File caching each account's repository list between runs, to skip listing it again")
+	repoCacheTTL := fs.Duration("repo-cache-ttl", 24*time.Hour, "How long cached repository lists stay fresh")
+	cacheDir := fs.String("cache-dir", "", "Directory caching successful GitHub API responses on disk, served without a request while fresh")
+	cacheTTL := fs.Duration("cache-ttl", time.Hour, "How long responses cached in -cache-dir stay fresh")
+	refresh := fs.Bool("refresh", false, "Fetch everything again, replacing what -repo-cache and -cache-dir hold")
+	proxyURL := fs.String("proxy", "", "Proxy for GitHub and WHOIS traffic, e.g. socks5://127.0.0.1:1080 (defaults to HTTP(S)_PROXY/ALL_PROXY)")
+	timeout := fs.Duration("timeout", 0, "Overall deadline for the GitHub scan, e.g. 30m (0 means no deadline)")
+	repoTimeout := fs.Duration("timeout-per-repo", 0, "Abandon a repository after this long, keeping the commits fetched so far (0 means no limit)")
+	strict := fs.Bool("strict", false, fmt.Sprintf("Exit with status %d when any repository, account or domain check failed, instead of only warning", exitIncomplete))
+	failOnLowQuota := fs.Bool("fail-on-low-quota", false, fmt.Sprintf("Abort before scanning when fewer than %d API requests remain", lowQuota))
+	userAgent := fs.String("user-agent", "gemails/"+version, "User-Agent header sent to the GitHub API")
+	configPath := fs.String("config", "", "YAML or TOML file of default flag values (defaults to gemails.yaml or gemails.toml in the working directory)")
+	encrypt := fs.Bool("encrypt", false, "Encrypt the output files with AES-GCM under a key derived from the passphrase with scrypt")
+	passphrase := fs.String("passphrase", "", "Passphrase for -encrypt and -decrypt (defaults to $"+passphraseEnv+", which keeps it out of argv)")
+	decryptPath := fs.String("decrypt", "", "Decrypt a file written with -encrypt to stdout, then exit")
+	showVersion := fs.Bool("version", false, "Print the version, commit and build date, then exit")
+	fs.Parse(args)
+
+	if *showVersion {
+		fmt.Println(versionString())
+		return
+	}
+
+	// Registered first so that it runs after every other deferred cleanup
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	// Fill in the flags left unset on the command line from the config file. collect skips
+	// the keys it has no flags for, such as the WHOIS ones of a file shared with report.
+	commandLine := setFlags(fs)
+	if path := findConfig(*configPath); path != "" {
+		if err := applyConfig(fs, path, !checkExpiry); err != nil {
+			fatalf("Error loading config file: %v", err)
+		}
+	}
+	configureColor(*noColor)
+
+	if *passphrase == "" {
+		*passphrase = os.Getenv(passphraseEnv)
+	}
+	if *decryptPath != "" {
+		if *passphrase == "" {
+			fatalf("-decrypt needs a passphrase: set $%s or use -passphrase", passphraseEnv)
+		}
+		if err := decryptFile(*decryptPath, *passphrase, os.Stdout); err != nil {
+			fatalf("Error decrypting: %v", err)
+		}
+		return
+	}
+	if *encrypt {
+		if *passphrase == "" {
+			fatalf("-encrypt needs a passphrase: set $%s or use -passphrase", passphraseEnv)
+		}
+		outputPassphrase = *passphrase
+	}
+
+	if *usernamesFile != "" {
+		names, err := readList(*usernamesFile)
+		if err != nil {
+			fatalf("Error reading usernames file: %v", err)
+		}
+		usernames = append(usernames, names...)
+	}
+	allowedDomains, err := expandFileEntries(whoisOnly)
+	if err != nil {
+		fatalf("Error reading -whois-only file: %v", err)
+	}
+
+	// A token file or the environment keep the token out of argv and shell history.
+	// Conflicting flags from the config file give way to those on the command line.
+	if *tokenFile != "" && *token != "" {
+		switch {
+		case yieldsTo(commandLine, "t", "t-file"):
+			*token = ""
+		case yieldsTo(commandLine, "t-file", "t"):
+			*tokenFile = ""
+		default:
+			fatalf("-t and -t-file cannot be combined; give the token only one way")
+		}
+	}
+	if *tokenFile != "" {
+		data, err := ioutil.ReadFile(*tokenFile)
+		if err != nil {
+			fatalf("Error reading token file: %v", err)
+		}
+		if *token = strings.TrimSpace(string(data)); *token == "" {
+			fatalf("Token file %s is empty", *tokenFile)
+		}
+	}
+	if *token == "" {
+		*token = os.Getenv("GITHUB_TOKEN")
+	}
+	if *token == "" {
+		*token = os.Getenv("GH_TOKEN")
+	}
+
+	// Validate inputs
+	useApp := *appID != 0 || *appInstallationID != 0 || *appKeyPath != ""
+	if useApp && (*appID == 0 || *appInstallationID == 0 || *appKeyPath == "") {
+		fatalf("GitHub App authentication needs all of -app-id, -app-installation-id and -app-key")
+	}
+	if len(usernames) == 0 || (*token == "" && !useApp) {
+		fatalf("Usage: gemails [%s] -u <username> -t <token> -o <output file> [-r <repo>]", name)
+	}
+	formatSet := setFlags(fs)["format"]
+	if len(outputFiles) == 0 {
+		outputFiles = listFlag{"emails.txt"}
+	}
+	if formatSet && len(outputFiles) > 1 {
+		if !yieldsTo(commandLine, "format", "o") {
+			fatalf("-format applies to a single -o; with several outputs the format comes from each file extension")
+		}
+		formatSet, *format = false, fs.Lookup("format").DefValue
+	}
+	if *streamOutput {
+		// Of -stream and an output option it excludes, the one on the command line wins
+		// over the other from the config file
+		for name, value := range map[string]*bool{"format": &formatSet, "append": appendOutput, "group-by-domain": groupByDomain} {
+			switch {
+			case !*value:
+			case yieldsTo(commandLine, name, "stream"):
+				*value = false
+			case yieldsTo(commandLine, "stream", name):
+				*streamOutput = false
+			}
+		}
+		if *streamOutput && len(outputFiles) > 1 && yieldsTo(commandLine, "stream", "o") {
+			*streamOutput = false
+		}
+	}
+	outputs, err := resolveOutputs(outputFiles, *format, formatSet)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	hasText := false
+	for _, out := range outputs {
+		hasText = hasText || out.format == "text"
+	}
+	if *appendOutput && !hasText {
+		fatalf("-append is only supported with the text format")
+	}
+	if *groupByDomain && (!hasText || *appendOutput) {
+		fatalf("-group-by-domain is only supported with the text format and without -append")
+	}
+	if *encrypt && (*appendOutput || *streamOutput) {
+		fatalf("-encrypt writes each output whole and cannot be combined with -append or -stream")
+	}
+	if *streamOutput && (len(outputs) > 1 || formatSet || *appendOutput || *groupByDomain) {
+		fatalf("-stream writes JSON lines to a single -o and cannot be combined with -format, -append or -group-by-domain")
+	}
+	sinceTime, err := parseSince(*since)
+	if err != nil {
+		fatalf("Invalid -since date %q: expected RFC3339 (2024-01-02T15:04:05Z) or YYYY-MM-DD", *since)
+	}
+	validateWhoisProxy(whoisProxy)
+	if *concurrency < 1 {
+		fatalf("Concurrency must be at least 1")
+	}
+	if checkExpiry {
+		if noWhois && *whois.output != "" {
+			fatalf("-whois-output cannot be combined with -no-whois")
+		}
+		if noWhois && *whois.failIfExpiring {
+			fatalf("-fail-if-expiring cannot be combined with -no-whois")
+		}
+		if noWhois && len(whois.expandTLDs) > 0 {
+			fatalf("-expand-tlds cannot be combined with -no-whois")
+		}
+		if noWhois && *whois.webhook != "" {
+			fatalf("-webhook cannot be combined with -no-whois")
+		}
+		if noWhois && len(allowedDomains) > 0 {
+			fatalf("-whois-only cannot be combined with -no-whois")
+		}
+		whois.validate()
+	}
+	if *freeDomainsPath != "" {
+		if err := loadFreeMailDomains(*freeDomainsPath); err != nil {
+			fatalf("Error reading free-mail domains file: %v", err)
+		}
+	}
+	if *repoOwner != "" && *starred {
+		fatalf("-owner cannot be combined with -starred, whose repositories have many owners")
+	}
+	if *refresh && *repoCachePath == "" && *cacheDir == "" {
+		fatalf("-refresh requires -repo-cache or -cache-dir")
+	}
+	if *resume && *checkpointPath == "" {
+		fatalf("-resume requires -checkpoint")
+	}
+	includePatterns, err := compilePatterns(includeRepos)
+	if err != nil {
+		fatalf("Invalid -include pattern: %v", err)
+	}
+	excludePatterns, err := compilePatterns(excludeRepos)
+	if err != nil {
+		fatalf("Invalid -exclude pattern: %v", err)
+	}
+	emailIncludePatterns, err := compileEmailPatterns(emailInclude)
+	if err != nil {
+		fatalf("Invalid -email-include pattern: %v", err)
+	}
+	emailExcludePatterns, err := compileEmailPatterns(emailExclude)
+	if err != nil {
+		fatalf("Invalid -email-exclude pattern: %v", err)
+	}
+
+	// Keep stdout clean for the email or domain list when piping
+	emailsToStdout := false
+	for _, out := range outputs {
+		emailsToStdout = emailsToStdout || out.path == "-"
+	}
+	if emailsToStdout && *domainsOut == "-" {
+		fatalf("-o - cannot be combined with -domains-out -, as both would write to stdout")
+	}
+	if emailsToStdout || *domainsOut == "-" {
+		statusOut = os.Stderr
+		color.Output = os.Stderr
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	// The first interrupt stops fetching so the emails collected so far are still saved;
+	// a second one exits immediately
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		warnf("Interrupted: saving the emails collected so far (interrupt again to exit immediately)")
+		stop()
+		<-signals
+		os.Exit(130)
+	}()
+
+	client := gemails.NewClient(*token)
+	client.BaseURL = strings.TrimSuffix(*apiURL, "/")
+	if useApp {
+		key, err := ioutil.ReadFile(*appKeyPath)
+		if err != nil {
+			fatalf("Error reading GitHub App private key: %v", err)
+		}
+		if client.App, err = gemails.NewAppAuth(*appID, *appInstallationID, key); err != nil {
+			fatalf("Error loading GitHub App private key: %v", err)
+		}
+	}
+	client.Concurrency = *concurrency
+	client.IncludeNoreply = *includeNoreply
+	client.IncludeBots = *includeBots
+	client.EmailInclude = emailIncludePatterns
+	client.EmailExclude = emailExcludePatterns
+	client.MaxRateLimitWait = *maxWait
+	client.RequestDelay = *delay
+	client.PageConcurrency = *pageConcurrency
+	client.Verbose = *verbose
+	client.Logger = log.New(libraryLog{}, "", 0)
+	client.DebugLogger = log.New(os.Stderr, "", log.LstdFlags)
+	client.Branch = *branch
+	client.SkipForks = *noForks
+	client.SkipArchived = *noArchived
+	client.Include = includePatterns
+	client.Exclude = excludePatterns
+	client.IncludePrivate = *includePrivate
+	client.MinCommits = *minCommits
+	client.MaxCommits = *maxCommits
+	client.MaxRepos = *maxRepos
+	client.Starred = *starred
+	client.RepoTimeout = *repoTimeout
+	client.Since = sinceTime
+	client.PullRequests = *pullRequests
+	client.Events = *events
+	client.RepoOwner = *repoOwner
+	client.Gists = *gists
+	client.Contributors = *contributors
+	client.UserAgent = *userAgent
+	if *proxyURL != "" {
+		if err := client.SetProxy(*proxyURL); err != nil {
+			fatalf("Error configuring proxy: %v", err)
+		}
+	}
+	configureWhoisProxy(*proxyURL, whoisProxy)
+
+	var stream *emailStream
+	if *streamOutput && !*dryRun {
+		var err error
+		stream, err = newEmailStream(outputs[0].path)
+		if err != nil {
+			fatalf("Error creating output file: %v", err)
+		}
+		client.OnEmail = stream.write
+	}
+	var db *database
+	if *dbPath != "" && !*dryRun {
+		var err error
+		if db, err = openDatabase(*dbPath); err != nil {
+			fatalf("Error opening database: %v", err)
+		}
+		defer db.Close()
+	}
+	var checkpoint *gemails.Checkpoint
+	if *checkpointPath != "" {
+		checkpoint = gemails.NewCheckpoint(*checkpointPath)
+		if *resume {
+			var err error
+			if checkpoint, err = gemails.LoadCheckpoint(*checkpointPath); err != nil {
+				fatalf("Error loading checkpoint: %v", err)
+			}
+			infof("Resuming: %d repositories already processed", checkpoint.Len())
+		}
+		client.Checkpoint = checkpoint
+	}
+	if *etagCachePath != "" {
+		etags, err := gemails.LoadETagCache(*etagCachePath)
+		if err != nil {
+			fatalf("Error loading ETag cache: %v", err)
+		}
+		client.ETags = etags
+		defer func() {
+			if err := etags.Save(*etagCachePath); err != nil {
+				errorf("Error saving ETag cache: %v", err)
+			}
+		}()
+	}
+	if *cacheDir != "" {
+		ttl := *cacheTTL
+		if *refresh {
+			ttl = 0
+		}
+		cache, err := gemails.NewDiskCache(*cacheDir, ttl)
+		if err != nil {
+			fatalf("Error creating response cache: %v", err)
+		}
+		client.Cache = cache
+	}
+	if *repoCachePath != "" {
+		maxAge := *repoCacheTTL
+		if *refresh {
+			maxAge = 0
+		}
+		repoCache, err := gemails.LoadRepoCache(*repoCachePath, maxAge)
+		if err != nil {
+			fatalf("Error loading repository cache: %v", err)
+		}
+		client.RepoCache = repoCache
+		defer func() {
+			if err := repoCache.Save(*repoCachePath); err != nil {
+				errorf("Error saving repository cache: %v", err)
+			}
+		}()
+	}
+
+	// Report the quota up front so a scan that will stall halfway is not started blindly
+	if limit, err := client.FetchRateLimit(ctx); err != nil {
+		errorf("Error checking rate limit: %v", err)
+	} else {
+		infof("Rate limit: %d/%d requests remaining, resets at %s",
+			limit.Remaining, limit.Limit, limit.Reset.Format("15:04:05"))
+		if limit.Remaining < lowQuota {
+			if *failOnLowQuota {
+				fatalf("Only %d API requests remain until %s; aborting", limit.Remaining, limit.Reset.Format("15:04:05"))
+			}
+			warnf("Only %d API requests remain; the scan may stall until %s", limit.Remaining, limit.Reset.Format("15:04:05"))
+		}
+	}
+
+	client.OnRepository = func(owner string, repo gemails.Repository, index, total int) {
+		infof("[%d/%d] Processing repository: %s/%s", index, total, owner, repo.Name)
+	}
+
+	// Collect from every account, merging the repositories each email was seen in.
+	// Repositories are qualified with their owner when several accounts are scanned.
+	emailRepos := make(map[string][]string)
+	emailNames := make(map[string]map[string]bool)
+	firstSeen := make(map[string]gemails.Sighting)
+	lastSeen := make(map[string]gemails.Sighting)
+	foundIn := make(map[string]string)
+	commitCounts := make(map[string]int)
+	summary := runSummary{scanned: true}
+	// finish prints the summary with the failures of the run, which fail it under -strict
+	finish := func() {
+		summary.Errors = failures()
+		summary.print(statusOut)
+		if *strict && len(summary.Errors) > 0 {
+			exitCode = exitIncomplete
+		}
+	}
+	for _, username := range usernames {
+		if ctx.Err() == context.Canceled {
+			break
+		}
+		var result *gemails.Result
+		if *repo != "" {
+			// Process only the specific repository, following it if it was renamed
+			owner := username
+			if *repoOwner != "" {
+				owner = *repoOwner
+			}
+			target, err := client.FetchRepository(ctx, owner, *repo)
+			if err != nil {
+				failf("Error looking up repository %s/%s, skipping: %v", owner, *repo, err)
+				continue
+			}
+			result = client.CollectFromRepos(ctx, username, []gemails.Repository{target})
+		} else {
+			// Fetch and process all repositories
+			var err error
+			result, err = client.CollectEmails(ctx, username)
+			if err != nil {
+				// One bad account (e.g. a 404) should not abort a batch scan
+				failf("Error collecting emails for %s, skipping: %v", username, err)
+				continue
+			}
+		}
+
+		qualify := func(name string) string {
+			// Event sources are already "owner/name"
+			if len(usernames) > 1 && !strings.Contains(name, "/") {
+				return username + "/" + name
+			}
+			return name
+		}
+		for email, repos := range result.Emails {
+			for _, name := range repos {
+				emailRepos[email] = append(emailRepos[email], qualify(name))
+			}
+		}
+		for email, n := range result.CommitCounts {
+			commitCounts[email] += n
+		}
+		// Earlier accounts were scanned first, so their repository keeps priority
+		for email, source := range result.FoundIn {
+			if _, ok := foundIn[email]; !ok {
+				foundIn[email] = qualify(source)
+			}
+		}
+		for email, seen := range result.FirstSeen {
+			if first, ok := firstSeen[email]; !ok || seen.Date.Before(first.Date) {
+				seen.Repository = qualify(seen.Repository)
+				firstSeen[email] = seen
+			}
+		}
+		for email, seen := range result.LastSeen {
+			if last, ok := lastSeen[email]; !ok || seen.Date.After(last.Date) {
+				seen.Repository = qualify(seen.Repository)
+				lastSeen[email] = seen
+			}
+		}
+		for email, names := range result.Names {
+			if emailNames[email] == nil {
+				emailNames[email] = make(map[string]bool)
+			}
+			for _, name := range names {
+				emailNames[email][name] = true
+			}
+		}
+		summary.FilteredNoreply += result.FilteredNoreply
+		summary.FilteredBots += result.FilteredBots
+		summary.FilteredPattern += result.FilteredPattern
+		summary.Rejected += result.Rejected
+		summary.Repositories += result.Repositories
+		summary.Commits += result.Commits
+		for _, err := range result.Errors {
+			recordFailure(fmt.Sprintf("%s: %v", username, err))
+		}
+	}
+
+	if checkpoint != nil {
+		if err := checkpoint.Save(); err != nil {
+			failf("Error saving checkpoint: %v", err)
+		}
+	}
+
+	// Track unique emails with their commit counts and their domains using maps
+	uniqueEmails := make(map[string]int)
+	uniqueDomains := make(map[string]bool)
+	domainEmails := make(map[string][]string)
+	for email := range emailRepos {
+		uniqueEmails[email] = commitCounts[email]
+		// Extract domain and add it to uniqueDomains map
+		if domain := gemails.ExtractDomain(email); domain != "" {
+			uniqueDomains[domain] = true
+			domainEmails[domain] = append(domainEmails[domain], email)
+		}
+	}
+	summary.Emails = len(uniqueEmails)
+	summary.Domains = len(uniqueDomains)
+
+	// A dry run only sizes the target: no output file and no WHOIS lookups
+	if *dryRun {
+		infof("\nDry run: no output written and no WHOIS checks run")
+		finish()
+		return
+	}
+
+	// Names are only written when requested
+	var names map[string][]string
+	if *withNames {
+		names = make(map[string][]string, len(emailNames))
+		for email, nameSet := range emailNames {
+			names[email] = sortedKeys(nameSet)
+		}
+	}
+
+	// Save unique emails to each output file, unless they were already streamed there
+	for _, out := range outputs {
+		switch {
+		case stream != nil:
+			stream.Close()
+		case out.format == "json":
+			saveEmailsJSON(emailRepos, names, foundIn, uniqueEmails, firstSeen, lastSeen, out.path)
+		case out.format == "csv":
+			saveEmailsCSV(emailRepos, foundIn, uniqueEmails, out.path)
+		case *groupByDomain:
+			saveEmailsByDomain(uniqueDomains, domainEmails, out.path)
+		default:
+			saveUniqueEmails(uniqueEmails, names, out.path, *appendOutput, *noSort, *withCounts)
+		}
+		if out.path != "-" {
+			infof("\nUnique emails saved to %s", out.path)
+		}
+	}
+	if *withCounts {
+		printTopCommitters(statusOut, uniqueEmails, topCommitters)
+	}
+	if db != nil {
+		if err := db.saveEmails(emailRepos, emailNames, foundIn, firstSeen); err != nil {
+			failf("Error writing to database: %v", err)
+		}
+	}
+	if *domainsOut != "" {
+		saveDomains(uniqueDomains, *domainsOut)
+		if *domainsOut != "-" {
+			infof("\nUnique domains saved to %s", *domainsOut)
+		}
+	}
+	// An interrupted scan skips the WHOIS checks and keeps its checkpoint for -resume
+	if ctx.Err() == context.Canceled {
+		finish()
+		return
+	}
+	// The scan is complete, so there is nothing left to resume
+	if checkpoint != nil && ctx.Err() == nil {
+		if err := os.Remove(*checkpointPath); err != nil && !os.IsNotExist(err) {
+			errorf("Error removing checkpoint: %v", err)
+		}
+	}
+
+	skip := append(append([]string(nil), publicEmailProviders...), splitList(whoisSkip)...)
+	checkedDomains := filterSkippedDomains(uniqueDomains, skip)
+
+	// Now, check the domain expiry for each unique domain
+	if !noWhois {
+		whoisDomains := checkedDomains
+		if len(allowedDomains) > 0 {
+			// Allowlisted domains are checked even if they are free-mail providers or skipped
+			whoisDomains = filterAllowedDomains(uniqueDomains, allowedDomains)
+			infof("\nRestricting WHOIS checks to %d of %d domains allowed by -whois-only", len(whoisDomains), len(uniqueDomains))
+		}
+		whois.run(whoisDomains, &summary, db)
+	}
+
+	if *checkMX {
+		checkDomainsMX(checkedDomains)
+	}
+
+	finish()
+	// An incomplete run takes precedence, as its expiry results may be missing domains
+	if checkExpiry && *whois.failIfExpiring && summary.Expiring > 0 && exitCode == 0 {
+		exitCode = exitExpiring
+	}
+}
+
+// output is one -o destination and the format written to it
+type output struct {
+	path   string
+	format string
+}
+
+// outputFormats maps output file extensions to their format
+var outputFormats = map[string]string{
+	".txt":  "text",
+	".json": "json",
+	".csv":  "csv",
+}
+
+// resolveOutputs picks the format of each output path from its extension. An explicit
+// -format overrides the extension, and stdout uses the -format value. It runs before the
+// scan, so a bad path fails immediately instead of after all the work is done.
+func resolveOutputs(paths []string, format string, formatSet bool) ([]output, error) {
+	switch format {
+	case "text", "json", "csv":
+	default:
+		return nil, fmt.Errorf("unknown output format %q (expected text, json or csv)", format)
+	}
+
+	outputs := make([]output, 0, len(paths))
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if seen[path] {
+			return nil, fmt.Errorf("output %q is given more than once", path)
+		}
+		seen[path] = true
+		if path == "-" || formatSet {
+			outputs = append(outputs, output{path, format})
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		f, ok := outputFormats[ext]
+		if !ok {
+			return nil, fmt.Errorf("cannot tell the output format of %q from its extension (use .txt, .json or .csv, or -format)", path)
+		}
+		outputs = append(outputs, output{path, f})
+	}
+	return outputs, nil
+}
+
+// parseSince parses a -since value in RFC3339 or YYYY-MM-DD form; empty means no filter
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// compilePatterns compiles repository name patterns, failing on the first invalid one
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := gemails.CompilePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// compileEmailPatterns compiles email regular expressions, failing on the first invalid one
+func compileEmailPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := gemails.CompileEmailPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// expandFileEntries replaces each @path entry of a list with the items of that file
+func expandFileEntries(entries []string) ([]string, error) {
+	var items []string
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry, "@") {
+			items = append(items, entry)
+			continue
+		}
+		fileItems, err := readList(strings.TrimPrefix(entry, "@"))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, fileItems...)
+	}
+	return items, nil
+}
+
+// readList reads one item per line, ignoring blank lines and # comments
+func readList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readListFrom(file)
+}
+
+// readListFrom is readList reading from r, e.g. stdin
+func readListFrom(r io.Reader) ([]string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names, nil
+}
+
+// sortedKeys returns the keys of a set in sorted order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// splitList splits a comma-separated flag value into its trimmed, non-empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// saveUniqueEmails saves unique emails to a specified file, sorted unless noSort is set.
+// When names is non-nil each email is followed by a tab and its "; "-separated names.
+// In append mode the addresses already present in the file are kept and not written again.
+func saveUniqueEmails(emails map[string]int, names map[string][]string, outputFile string, appendMode, noSort, withCounts bool) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	existing := make(map[string]bool)
+	if appendMode && outputFile != "-" {
+		flags = os.O_APPEND | os.O_CREATE | os.O_WRONLY
+		existing = loadEmails(outputFile)
+	}
+
+	file, err := openOutput(outputFile, flags)
+	if err != nil {
+		fatalf("Error creating output file: %v", err)
+	}
+	defer closeOutput(file)
+
+	list := make([]string, 0, len(emails))
+	for email := range emails {
+		list = append(list, email)
+	}
+	if !noSort {
+		sort.Strings(list)
+	}
+
+	for _, email := range list {
+		if existing[email] {
+			continue
+		}
+		line := email
+		if withCounts {
+			line += "\t" + strconv.Itoa(emails[email])
+		}
+		if names != nil && len(names[email]) > 0 {
+			line += "\t" + strings.Join(names[email], "; ")
+		}
+		if _, err := io.WriteString(file, line+"\n"); err != nil {
+			fatalf("Error writing to output file: %v", err)
+		}
+	}
+}
+
+// saveEmailsByDomain saves emails grouped under "domain:" headers, both in sorted order
+func saveEmailsByDomain(domains map[string]bool, domainEmails map[string][]string, outputFile string) {
+	file, err := openOutput(outputFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
+	if err != nil {
+		fatalf("Error creating output file: %v", err)
+	}
+	defer closeOutput(file)
+
+	for i, domain := range sortedKeys(domains) {
+		if i > 0 {
+			io.WriteString(file, "\n")
+		}
+		emails := domainEmails[domain]
+		sort.Strings(emails)
+
+		if _, err := fmt.Fprintf(file, "%s:\n", domain); err != nil {
+			fatalf("Error writing to output file: %v", err)
+		}
+		for _, email := range emails {
+			if _, err := fmt.Fprintf(file, "  %s\n", email); err != nil {
+				fatalf("Error writing to output file: %v", err)
+			}
+		}
+	}
+}
+
+// saveDomains saves the sorted domains to a file, one per line
+func saveDomains(domains map[string]bool, outputFile string) {
+	file, err := openOutput(outputFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
+	if err != nil {
+		fatalf("Error creating domains file: %v", err)
+	}
+	defer closeOutput(file)
+
+	for _, domain := range sortedKeys(domains) {
+		if _, err := io.WriteString(file, domain+"\n"); err != nil {
+			fatalf("Error writing to domains file: %v", err)
+		}
+	}
+}
+
+// nopWriteCloser lets stdout stand in for an output file without being closed
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// openOutput opens the output file with the given flags, or stdout when the path is "-".
+// Missing parent directories are created, and new files are readable by their owner only.
+// With -encrypt, the content is encrypted on Close.
+func openOutput(path string, flags int) (io.WriteCloser, error) {
+	var file io.WriteCloser = nopWriteCloser{os.Stdout}
+	if path != "-" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("error creating output directory: %w", err)
+		}
+		// Encrypted or not, harvested emails are not for other local users to read
+		f, err := os.OpenFile(path, flags, 0600)
+		if err != nil {
+			return nil, err
+		}
+		file = f
+	}
+	if outputPassphrase != "" {
+		return &encryptedOutput{file: file, passphrase: outputPassphrase}, nil
+	}
+	return file, nil
+}
+
+// closeOutput closes an output file, which for -encrypt is when it is written
+func closeOutput(file io.WriteCloser) {
+	if err := file.Close(); err != nil {
+		fatalf("Error writing to output file: %v", err)
+	}
+}
+
+// topCommitters is how many of the most active emails -with-counts prints
+const topCommitters = 20
+
+// printTopCommitters prints up to limit emails with the most commits, most active first
+func printTopCommitters(w io.Writer, counts map[string]int, limit int) {
+	emails := make([]string, 0, len(counts))
+	for email := range counts {
+		emails = append(emails, email)
+	}
+	sort.Slice(emails, func(i, j int) bool {
+		if counts[emails[i]] != counts[emails[j]] {
+			return counts[emails[i]] > counts[emails[j]]
+		}
+		return emails[i] < emails[j]
+	})
+
+	fmt.Fprintf(w, "\nMost active committers:\n")
+	for i, email := range emails {
+		if i == limit {
+			fmt.Fprintf(w, "  ... and %d more\n", len(emails)-limit)
+			break
+		}
+		fmt.Fprintf(w, "  %6d  %s\n", counts[email], email)
+	}
+}
+
+// loadEmails reads a previously saved one-email-per-line file; a missing file yields an empty set
+func loadEmails(path string) map[string]bool {
+	emails := make(map[string]bool)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fatalf("Error reading existing output file: %v", err)
+		}
+		return emails
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// Lines written with -names carry the names after a tab
+		if fields := strings.Fields(strings.SplitN(line, "\t", 2)[0]); len(fields) > 0 {
+			emails[fields[0]] = true
+		}
+	}
+	return emails
+}
+
+// saveEmailsJSON saves unique emails with their domain, repositories, the repository that
+// first yielded them, commit count and first and last commits as JSON, including their
+// names when names is non-nil
+func saveEmailsJSON(emailRepos map[string][]string, names map[string][]string, foundIn map[string]string, counts map[string]int, firstSeen, lastSeen map[string]gemails.Sighting, outputFile string) {
+	records := make([]EmailRecord, 0, len(emailRepos))
+	domains := make(map[string]bool)
+	for email, repos := range emailRepos {
+		domain := gemails.ExtractDomain(email)
+		if domain != "" {
+			domains[domain] = true
+		}
+		record := EmailRecord{Email: email, Names: names[email], Domain: domain, DomainType: domainType(domain), Repositories: repos, FoundIn: foundIn[email], Commits: counts[email]}
+		if seen, ok := firstSeen[email]; ok {
+			record.FirstSeen = &seen
+		}
+		if seen, ok := lastSeen[email]; ok {
+			record.LastSeen = &seen
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Email < records[j].Email })
+
+	domainList := make([]string, 0, len(domains))
+	for domain := range domains {
+		domainList = append(domainList, domain)
+	}
+	sort.Strings(domainList)
+
+	output := struct {
+		Emails  []EmailRecord `json:"emails"`
+		Domains []string      `json:"domains"`
+	}{records, domainList}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		fatalf("Error encoding JSON output: %v", err)
+	}
+	file, err := openOutput(outputFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
+	if err != nil {
+		fatalf("Error creating output file: %v", err)
+	}
+	defer closeOutput(file)
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		fatalf("Error writing to output file: %v", err)
+	}
+}
+
+// saveEmailsCSV saves one email,domain,repository,found_in,commits,domain_type row per observation
+// as CSV, where found_in is the repository that first yielded the email and commits its commit count
+func saveEmailsCSV(emailRepos map[string][]string, foundIn map[string]string, counts map[string]int, outputFile string) {
+	file, err := openOutput(outputFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
+	if err != nil {
+		fatalf("Error creating output file: %v", err)
+	}
+	defer closeOutput(file)
+
+	emails := make([]string, 0, len(emailRepos))
+	for email := range emailRepos {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+
+	w := csv.NewWriter(file)
+	if err := w.Write([]string{"email", "domain", "repository", "found_in", "commits", "domain_type"}); err != nil {
+		fatalf("Error writing to output file: %v", err)
+	}
+	for _, email := range emails {
+		domain := gemails.ExtractDomain(email)
+		for _, repo := range emailRepos[email] {
+			if err := w.Write([]string{email, domain, repo, foundIn[email], strconv.Itoa(counts[email]), domainType(domain)}); err != nil {
+				fatalf("Error writing to output file: %v", err)
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fatalf("Error writing to output file: %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"net"
+
+	"github.com/fatih/color"
+)
+
+// checkDomainsMX looks up the MX records of each domain and flags domains that cannot receive mail
+func checkDomainsMX(domains map[string]bool) {
+	for _, domain := range sortedKeys(domains) {
+		records, err := net.LookupMX(domain)
+		if err != nil {
+			var dnsErr *net.DNSError
+			if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+				failf("Error looking up MX records for domain %s: %v", domain, err)
+				continue
+			}
+		}
+
+		if len(records) == 0 {
+			color.Red("Domain %s has no MX records (cannot receive mail)", domain)
+		} else {
+			color.Green("Domain %s has %d MX records (primary %s)", domain, len(records), records[0].Host)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rdapBootstrapURL is IANA's registry of the RDAP server of each TLD
+const rdapBootstrapURL = "https://data.iana.org/rdap/dns.json"
+
+// rdapFallbackURL is an RDAP redirector used when the bootstrap registry cannot be fetched
+const rdapFallbackURL = "https://rdap.org/"
+
+// rdapClient performs RDAP lookups; configureWhoisProxy gives it the WHOIS proxy settings
+var rdapClient = &http.Client{Timeout: 15 * time.Second}
+
+// errRDAPNoServer reports a domain whose TLD has no known RDAP server
+var errRDAPNoServer = errors.New("no RDAP server found")
+
+// errRDAPNotFound reports a domain that the RDAP server of its TLD, as listed in the
+// bootstrap registry, has no record of, i.e. one that is not registered
+var errRDAPNotFound = errors.New("no RDAP record found")
+
+// rdapServers maps TLDs to their RDAP base URL, loaded once from the bootstrap registry
+var rdapServers struct {
+	once  sync.Once
+	byTLD map[string]string
+	err   error
+}
+
+// rdapServerFor returns the RDAP base URL, ending in "/", responsible for domain.
+// It reports false when the TLD has no RDAP service.
+func rdapServerFor(domain string) (string, bool) {
+	rdapServers.once.Do(func() {
+		rdapServers.byTLD, rdapServers.err = loadRDAPBootstrap()
+		if rdapServers.err != nil {
+			warnf("Could not load the RDAP bootstrap registry, using %s: %v", rdapFallbackURL, rdapServers.err)
+		}
+	})
+	if rdapServers.err != nil {
+		return rdapFallbackURL, true
+	}
+
+	labels := strings.Split(strings.ToLower(domain), ".")
+	for i := 1; i < len(labels); i++ {
+		if server, ok := rdapServers.byTLD[strings.Join(labels[i:], ".")]; ok {
+			return server, true
+		}
+	}
+	return "", false
+}
+
+// loadRDAPBootstrap fetches the bootstrap registry, whose services pair TLD lists with
+// server URLs: {"services": [[["com", "net"], ["https://rdap.verisign.com/com/v1/"]], ...]}
+func loadRDAPBootstrap() (map[string]string, error) {
+	resp, err := rdapClient.Get(rdapBootstrapURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var bootstrap struct {
+		Services [][][]string `json:"services"`
+	}
+	if err := json.Unmarshal(body, &bootstrap); err != nil {
+		return nil, fmt.Errorf("error unmarshaling RDAP bootstrap registry: %w", err)
+	}
+	servers := make(map[string]string)
+	for _, service := range bootstrap.Services {
+		if len(service) < 2 || len(service[1]) == 0 {
+			continue
+		}
+		// Prefer an HTTPS server when several are listed
+		server := service[1][0]
+		for _, candidate := range service[1] {
+			if strings.HasPrefix(candidate, "https://") {
+				server = candidate
+				break
+			}
+		}
+		if !strings.HasSuffix(server, "/") {
+			server += "/"
+		}
+		for _, tld := range service[0] {
+			servers[strings.ToLower(tld)] = server
+		}
+	}
+	return servers, nil
+}
+
+// rdapDomain is the part of an RDAP domain response that is used
+type rdapDomain struct {
+	Events []struct {
+		Action string    `json:"eventAction"`
+		Date   time.Time `json:"eventDate"`
+	} `json:"events"`
+	Entities    []rdapEntity `json:"entities"`
+	Nameservers []struct {
+		LDHName string `json:"ldhName"`
+	} `json:"nameservers"`
+}
+
+// rdapEntity is a contact of an RDAP record, such as its registrar
+type rdapEntity struct {
+	Roles      []string        `json:"roles"`
+	VCardArray json.RawMessage `json:"vcardArray"`
+}
+
+// lookupRDAP queries the RDAP server of domain's TLD. Unlike free-text WHOIS, the dates
+// come as structured events, so they parse reliably.
+func lookupRDAP(domain string) (DomainInfo, error) {
+	server, ok := rdapServerFor(domain)
+	if !ok {
+		return DomainInfo{}, errRDAPNoServer
+	}
+	url := server + "domain/" + domain
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return DomainInfo{}, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := rdapClient.Do(req)
+	if err != nil {
+		return DomainInfo{}, err
+	}
+	defer resp.Body.Close()
+	// Only the TLD's own server is authoritative about a domain not being registered
+	if resp.StatusCode == http.StatusNotFound && server != rdapFallbackURL {
+		return DomainInfo{}, errRDAPNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		return DomainInfo{}, fmt.Errorf("RDAP returned status code %d for %s", resp.StatusCode, url)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return DomainInfo{}, err
+	}
+
+	var record rdapDomain
+	if err := json.Unmarshal(body, &record); err != nil {
+		return DomainInfo{}, fmt.Errorf("error unmarshaling RDAP response: %w", err)
+	}
+	return record.info(), nil
+}
+
+// info converts the RDAP record into a DomainInfo
+func (r rdapDomain) info() DomainInfo {
+	var info DomainInfo
+	for _, event := range r.Events {
+		switch event.Action {
+		case "expiration":
+			info.Expiry = event.Date
+		case "registration":
+			info.Created = event.Date
+		}
+	}
+	for _, entity := range r.Entities {
+		if containsFold(entity.Roles, "registrar") {
+			info.Registrar = vcardName(entity.VCardArray)
+		}
+	}
+	for _, ns := range r.Nameservers {
+		info.NameServers = append(info.NameServers, strings.ToLower(ns.LDHName))
+	}
+	return info
+}
+
+// vcardName returns the "fn" (formatted name) property of a jCard such as
+// ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Example Registrar"]]]
+func vcardName(raw json.RawMessage) string {
+	var card []json.RawMessage
+	if json.Unmarshal(raw, &card) != nil || len(card) < 2 {
+		return ""
+	}
+	var properties [][]interface{}
+	if json.Unmarshal(card[1], &properties) != nil {
+		return ""
+	}
+	for _, property := range properties {
+		if len(property) >= 4 && property[0] == "fn" {
+			if name, ok := property[3].(string); ok {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// containsFold reports whether list contains s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// streamRecord is one JSON line of streamed output
+type streamRecord struct {
+	Email string `json:"email"`
+	Repo  string `json:"repo"`
+}
+
+// emailStream writes each newly discovered email as a JSON line as soon as it is found,
+// so a crash mid-scan keeps everything written so far
+type emailStream struct {
+	mu   sync.Mutex
+	out  io.WriteCloser
+	enc  *json.Encoder
+	seen map[string]bool
+}
+
+// newEmailStream truncates and opens the output file for streaming, or stdout when the path is "-"
+func newEmailStream(outputFile string) (*emailStream, error) {
+	out, err := openOutput(outputFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
+	if err != nil {
+		return nil, err
+	}
+	return &emailStream{out: out, enc: json.NewEncoder(out), seen: make(map[string]bool)}, nil
+}
+
+// write emits email unless it was already streamed; it is safe for concurrent use
+func (s *emailStream) write(email, repo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seen[email] {
+		return
+	}
+	s.seen[email] = true
+	if err := s.enc.Encode(streamRecord{Email: email, Repo: repo}); err != nil {
+		fatalf("Error writing to output file: %v", err)
+	}
+}
+
+// Close closes the underlying output
+func (s *emailStream) Close() error {
+	return s.out.Close()
+}
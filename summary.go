@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// runSummary holds the counters reported at the end of a run
+type runSummary struct {
+	// The GitHub counters are only reported when scanned is set, i.e. not for the whois command
+	scanned         bool
+	Repositories    int
+	Commits         int
+	Emails          int
+	Domains         int
+	FilteredNoreply int
+	FilteredBots    int
+	FilteredPattern int
+	Rejected        int
+	// Expiring and Unregistered are only reported once WHOIS checks have run
+	Expiring      int
+	Unregistered  int
+	checkedExpiry bool
+	// AvailableSiblings counts the unregistered -expand-tlds variants
+	AvailableSiblings int
+	checkedSiblings   bool
+	// Errors lists the non-fatal failures that left the run incomplete
+	Errors []string
+}
+
+// print writes the summary block to w
+func (s *runSummary) print(w io.Writer) {
+	fmt.Fprintf(w, "\nSummary:\n")
+	if s.scanned {
+		fmt.Fprintf(w, "  Repositories processed:    %d\n", s.Repositories)
+		fmt.Fprintf(w, "  Commits seen:              %d\n", s.Commits)
+		fmt.Fprintf(w, "  Unique emails:             %d\n", s.Emails)
+	}
+	fmt.Fprintf(w, "  Unique domains:            %d\n", s.Domains)
+	if s.FilteredNoreply > 0 {
+		fmt.Fprintf(w, "  Noreply addresses skipped: %d (use -include-noreply to keep them)\n", s.FilteredNoreply)
+	}
+	if s.FilteredBots > 0 {
+		fmt.Fprintf(w, "  Bot addresses skipped:     %d (use -include-bots to keep them)\n", s.FilteredBots)
+	}
+	if s.FilteredPattern > 0 {
+		fmt.Fprintf(w, "  Filtered by -email-*:      %d\n", s.FilteredPattern)
+	}
+	if s.Rejected > 0 {
+		fmt.Fprintf(w, "  Invalid addresses skipped: %d\n", s.Rejected)
+	}
+	if s.checkedExpiry {
+		fmt.Fprintf(w, "  Domains nearing expiry:    %d\n", s.Expiring)
+		if s.Unregistered > 0 {
+			fmt.Fprintf(w, "  Unregistered domains:      %d\n", s.Unregistered)
+		}
+	}
+	if s.checkedSiblings {
+		fmt.Fprintf(w, "  Available sibling domains: %d\n", s.AvailableSiblings)
+	}
+	if len(s.Errors) > 0 {
+		fmt.Fprintf(w, "\nErrors encountered (%d), the results may be incomplete:\n", len(s.Errors))
+		for _, msg := range s.Errors {
+			fmt.Fprintf(w, "  - %s\n", msg)
+		}
+	}
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/likexian/whois"
+	"golang.org/x/net/proxy"
+)
+
+// whoisClient performs all WHOIS lookups so that they share the proxy configuration
+var whoisClient = whois.NewClient()
+
+// whoisAttempts is how many times a failing WHOIS lookup is tried, whoisRetryDelay apart
+const (
+	whoisAttempts   = 3
+	whoisRetryDelay = 2 * time.Second
+)
+
+// configureWhoisProxy routes WHOIS lookups through whoisProxyURL, falling back to proxyURL
+// and then to ALL_PROXY. Only SOCKS proxies can carry WHOIS traffic; other schemes leave
+// lookups direct. RDAP lookups, being HTTP, use proxyURL or the HTTP(S)_PROXY variables.
+func configureWhoisProxy(proxyURL, whoisProxyURL string) {
+	// Keep the default dial, TLS and idle timeouts, changing only the proxy
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if u, err := url.Parse(proxyURL); err == nil && proxyURL != "" {
+		transport.Proxy = http.ProxyURL(u)
+	}
+	rdapClient.Transport = transport
+
+	if whoisProxyURL == "" {
+		whoisProxyURL = proxyURL
+	}
+	if whoisProxyURL == "" {
+		whoisClient.SetDialer(proxy.FromEnvironment())
+		return
+	}
+	u, err := url.Parse(whoisProxyURL)
+	if err != nil {
+		warnf("Invalid WHOIS proxy %q, WHOIS lookups will not be proxied: %v", whoisProxyURL, err)
+		return
+	}
+	dialer, err := proxy.FromURL(u, proxy.Direct)
+	if err != nil {
+		warnf("WHOIS lookups cannot use proxy %s and will not be proxied: %v", whoisProxyURL, err)
+		return
+	}
+	whoisClient.SetDialer(dialer)
+}
+
+// validateWhoisProxy exits unless whoisProxyURL, the -proxy-whois flag, is empty or a SOCKS proxy
+func validateWhoisProxy(whoisProxyURL string) {
+	if whoisProxyURL == "" {
+		return
+	}
+	if u, err := url.Parse(whoisProxyURL); err != nil || (u.Scheme != "socks5" && u.Scheme != "socks5h") {
+		fatalf("Invalid -proxy-whois %q: WHOIS can only use a socks5:// or socks5h:// proxy", whoisProxyURL)
+	}
+}
+
+// publicEmailProviders lists free-mail domains that are never worth a WHOIS expiry check
+var publicEmailProviders = []string{
+	"gmail.com", "googlemail.com", "outlook.com", "hotmail.com", "live.com", "msn.com",
+	"yahoo.com", "ymail.com", "icloud.com", "me.com", "mac.com", "aol.com",
+	"protonmail.com", "proton.me", "pm.me", "gmx.com", "gmx.de", "gmx.net", "web.de",
+	"mail.com", "mail.ru", "yandex.ru", "yandex.com", "qq.com", "163.com", "126.com",
+	"zoho.com", "fastmail.com", "tutanota.com", "hey.com",
+}
+
+// filterSkippedDomains returns the domains that are not in skip, compared case-insensitively
+func filterSkippedDomains(domains map[string]bool, skip []string) map[string]bool {
+	skipped := make(map[string]bool, len(skip))
+	for _, domain := range skip {
+		skipped[strings.ToLower(domain)] = true
+	}
+
+	filtered := make(map[string]bool, len(domains))
+	for domain := range domains {
+		if !skipped[strings.ToLower(domain)] {
+			filtered[domain] = true
+		}
+	}
+	return filtered
+}
+
+// filterAllowedDomains returns the domains that are in allow or subdomains of one of them,
+// compared case-insensitively
+func filterAllowedDomains(domains map[string]bool, allow []string) map[string]bool {
+	filtered := make(map[string]bool)
+	for domain := range domains {
+		name := strings.ToLower(domain)
+		for _, allowed := range allow {
+			allowed = strings.ToLower(strings.TrimSuffix(allowed, "."))
+			if name == allowed || strings.HasSuffix(name, "."+allowed) {
+				filtered[domain] = true
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// whoisChecker runs WHOIS expiry checks with a shared cache, warning threshold and parallelism
+type whoisChecker struct {
+	// cache, when non-nil, serves and stores lookup results
+	cache *whoisCache
+	// expiryDays is the warning threshold
+	expiryDays int
+	// concurrency bounds parallel lookups; WHOIS servers throttle aggressive clients
+	concurrency int
+	// noRDAP skips RDAP and only queries WHOIS
+	noRDAP bool
+	// format is "lines" for one colored line per domain or "table" for aligned columns
+	format string
+	// verbose also prints the registrar, creation date and name servers of each domain
+	verbose bool
+	// servers maps TLDs (e.g. "de" or "co.uk") to the WHOIS server to query instead of the default
+	servers map[string]string
+}
+
+// serverFor returns the WHOIS server configured for the longest matching TLD of domain, or ""
+func (w *whoisChecker) serverFor(domain string) string {
+	labels := strings.Split(strings.ToLower(domain), ".")
+	for i := 1; i < len(labels); i++ {
+		if server, ok := w.servers[strings.Join(labels[i:], ".")]; ok {
+			return server
+		}
+	}
+	return ""
+}
+
+// DomainInfo is what is parsed from a domain's WHOIS record; zero fields were not found
+type DomainInfo struct {
+	Expiry      time.Time `json:"expiry"`
+	Created     time.Time `json:"created"`
+	Registrar   string    `json:"registrar,omitempty"`
+	NameServers []string  `json:"name_servers,omitempty"`
+}
+
+// domainExpiry is the outcome of a single domain's expiry lookup
+type domainExpiry struct {
+	domain string
+	info   DomainInfo
+	err    error
+	// available reports that the domain is not registered, so anyone can register it
+	available bool
+}
+
+// daysLeft returns the whole days until the domain expires
+func (d domainExpiry) daysLeft() int {
+	return int(time.Until(d.info.Expiry).Hours() / 24)
+}
+
+// checkDomainsExpiry checks WHOIS info for each domain and warns when fewer than
+// expiryDays remain. It returns the results in domain order and how many domains
+// are nearing expiry. Lookups run in parallel; results are printed once all are collected.
+func (w *whoisChecker) checkDomainsExpiry(domains map[string]bool) ([]domainExpiry, int) {
+	concurrency := w.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan string)
+	results := make(chan domainExpiry)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for domain := range jobs {
+				results <- w.lookupExpiry(domain)
+			}
+		}()
+	}
+	go func() {
+		for domain := range domains {
+			jobs <- domain
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var expiries []domainExpiry
+	for result := range results {
+		expiries = append(expiries, result)
+	}
+	sortByExpiry(expiries)
+
+	expiring := 0
+	for _, result := range expiries {
+		if w.status(result) == "expiring" {
+			expiring++
+		}
+	}
+	if w.format == "table" {
+		w.printTable(expiries)
+	} else {
+		w.printLines(expiries)
+	}
+
+	if w.cache != nil {
+		if err := w.cache.save(); err != nil {
+			errorf("Error saving WHOIS cache: %v", err)
+		}
+	}
+	return expiries, expiring
+}
+
+// countAvailable returns how many of the results are unregistered domains
+func countAvailable(expiries []domainExpiry) int {
+	available := 0
+	for _, result := range expiries {
+		if result.available {
+			available++
+		}
+	}
+	return available
+}
+
+// sortByExpiry orders the unregistered domains first, then the others soonest to expire
+// first, followed by the domains without a known expiry; ties are ordered by domain
+func sortByExpiry(expiries []domainExpiry) {
+	rank := func(d domainExpiry) int {
+		switch {
+		case d.available:
+			return 0
+		case d.err == nil && !d.info.Expiry.IsZero():
+			return 1
+		}
+		return 2
+	}
+	sort.Slice(expiries, func(i, j int) bool {
+		a, b := expiries[i], expiries[j]
+		switch {
+		case rank(a) != rank(b):
+			return rank(a) < rank(b)
+		case rank(a) == 1 && !a.info.Expiry.Equal(b.info.Expiry):
+			return a.info.Expiry.Before(b.info.Expiry)
+		}
+		return a.domain < b.domain
+	})
+}
+
+// printLines prints one colored line per domain: red when nearing expiry, green otherwise
+func (w *whoisChecker) printLines(expiries []domainExpiry) {
+	for _, result := range expiries {
+		if result.err != nil {
+			failf("Error fetching WHOIS info for domain %s: %v", result.domain, result.err)
+			continue
+		}
+		if result.available {
+			color.Red("Domain %s is not registered and can be registered by anyone", result.domain)
+			continue
+		}
+		if result.info.Expiry.IsZero() {
+			warnf("No expiry date found for domain %s", result.domain)
+			continue
+		}
+
+		// Compare the expiry date with today's date
+		if w.isExpiring(result) {
+			color.Red("Domain %s is nearing expiry (Expires on %s, %d days left)", result.domain, result.info.Expiry.Format("2006-01-02"), result.daysLeft())
+		} else {
+			color.Green("Domain %s has a valid expiry date (Expires on %s, %d days left)", result.domain, result.info.Expiry.Format("2006-01-02"), result.daysLeft())
+		}
+		if w.verbose {
+			printDomainInfo(result.info)
+		}
+	}
+}
+
+// printTable prints the results as aligned domain, expiry, days left and status columns,
+// coloring the days left red or green
+func (w *whoisChecker) printTable(expiries []domainExpiry) {
+	// Every days-left cell gets an escape sequence of the same length, or none without
+	// colors, so that the invisible bytes do not skew the alignment
+	noColor := color.New(color.Attribute(39)) // default foreground
+	cell := func(c *color.Color, s string) string {
+		if color.NoColor {
+			return s
+		}
+		return c.Sprint(s)
+	}
+
+	tw := tabwriter.NewWriter(color.Output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOMAIN\tEXPIRY\tDAYS LEFT\tSTATUS")
+	for _, result := range expiries {
+		expiry, days := "-", cell(noColor, "-")
+		if result.err == nil && !result.info.Expiry.IsZero() {
+			expiry = result.info.Expiry.Format("2006-01-02")
+			days = cell(color.New(color.FgGreen), strconv.Itoa(result.daysLeft()))
+			if w.isExpiring(result) {
+				days = cell(color.New(color.FgRed), strconv.Itoa(result.daysLeft()))
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.domain, expiry, days, w.status(result))
+	}
+	tw.Flush()
+
+	for _, result := range expiries {
+		if result.err != nil {
+			failf("Error fetching WHOIS info for domain %s: %v", result.domain, result.err)
+		}
+	}
+}
+
+// status classifies a result as "valid", "expiring", "available" (not registered),
+// "unknown" (no expiry date found) or "error"
+func (w *whoisChecker) status(d domainExpiry) string {
+	switch {
+	case d.err != nil:
+		return "error"
+	case d.available:
+		return "available"
+	case d.info.Expiry.IsZero():
+		return "unknown"
+	case w.isExpiring(d):
+		return "expiring"
+	}
+	return "valid"
+}
+
+// isExpiring reports whether a domain with a known expiry date is within the warning threshold
+func (w *whoisChecker) isExpiring(d domainExpiry) bool {
+	return time.Until(d.info.Expiry).Hours()/24 < float64(w.expiryDays)
+}
+
+// printDomainInfo prints the registrar, creation date and name servers found for a domain
+func printDomainInfo(info DomainInfo) {
+	registrar, created, nameServers := "unknown", "unknown", "unknown"
+	if info.Registrar != "" {
+		registrar = info.Registrar
+	}
+	if !info.Created.IsZero() {
+		created = info.Created.Format("2006-01-02")
+	}
+	if len(info.NameServers) > 0 {
+		nameServers = strings.Join(info.NameServers, ", ")
+	}
+	fmt.Fprintf(color.Output, "  Registrar: %s, created: %s, name servers: %s\n", registrar, created, nameServers)
+}
+
+// lookupExpiry returns the WHOIS details of domain, from the cache when fresh or via WHOIS.
+// Subdomains such as eng.example.com have no registration of their own, so the domain
+// they are registered under is looked up; only that domain itself can be reported available.
+func (w *whoisChecker) lookupExpiry(domain string) domainExpiry {
+	name := registrableDomain(domain)
+	if name == "" {
+		name = domain
+	}
+	if w.cache != nil {
+		if info, ok := w.cache.get(name); ok {
+			return domainExpiry{domain: domain, info: info}
+		}
+	}
+
+	// Prefer RDAP's structured data and fall back to WHOIS when it has no expiry date.
+	// Unregistered domains are not cached, as someone may register them at any time.
+	var info DomainInfo
+	if !w.noRDAP {
+		rdapInfo, err := lookupRDAP(name)
+		if errors.Is(err, errRDAPNotFound) && name == domain {
+			return domainExpiry{domain: domain, available: true}
+		}
+		if err == nil && !rdapInfo.Expiry.IsZero() {
+			info = rdapInfo
+		}
+	}
+	if info.Expiry.IsZero() {
+		whoisInfo, err := w.whois(name)
+		if err != nil {
+			return domainExpiry{domain: domain, err: err}
+		}
+		info = parseWhois(whoisInfo)
+		if whoisNotFound(whoisInfo, info) {
+			if name == domain {
+				return domainExpiry{domain: domain, available: true}
+			}
+			return domainExpiry{domain: domain}
+		}
+	}
+
+	if w.cache != nil {
+		w.cache.put(name, info)
+	}
+	return domainExpiry{domain: domain, info: info}
+}
+
+// whois queries WHOIS for domain, retrying transient failures. An empty server from
+// serverFor lets the library pick one.
+func (w *whoisChecker) whois(domain string) (string, error) {
+	var err error
+	for attempt := 1; attempt <= whoisAttempts; attempt++ {
+		var whoisInfo string
+		if whoisInfo, err = whoisClient.Whois(domain, w.serverFor(domain)); err == nil {
+			return whoisInfo, nil
+		}
+		if attempt < whoisAttempts {
+			time.Sleep(whoisRetryDelay)
+		}
+	}
+	return "", err
+}
+
+// whoisNotFoundRegex matches the ways WHOIS servers say a domain is not registered, e.g.
+// "No match for", "Domain not found." or "Status: free"
+var whoisNotFoundRegex = regexp.MustCompile(`(?im)^\s*%*\s*(?:no match(?:es)?\b|(?:domain )?not found\b|no data found|no entries found|no object found|the queried object does not exist|status:\s*(?:free|available)\s*$)`)
+
+// whoisNotFound reports whether a WHOIS response, parsed into info, says the domain is not
+// registered. With referrals the registrar's answer follows the registry's, and a registrar
+// may have no record of a domain the registry has, so a not-found message only counts when
+// no registration data was found.
+func whoisNotFound(whoisInfo string, info DomainInfo) bool {
+	if !info.Expiry.IsZero() || !info.Created.IsZero() || info.Registrar != "" {
+		return false
+	}
+	return whoisNotFoundRegex.MatchString(whoisInfo)
+}
+
+// registrarRegex matches the registrar field, e.g. "Registrar:" or "Sponsoring Registrar:"
+var registrarRegex = regexp.MustCompile(`(?im)^\s*(?:sponsoring )?registrar(?: name)?\s*:[ \t]*(\S.*?)\s*$`)
+
+// createdRegex matches the creation date field, e.g. "Creation Date:", "created:" or "Registered on:"
+var createdRegex = regexp.MustCompile(`(?im)^\s*\[?(?:creation date|created(?: on| date)?|registered(?: on)?|registration (?:date|time)|domain registration date)\]?[\s.]*:?[ \t]*(\S.*?)\s*$`)
+
+// nameServerRegex matches one name server field, e.g. "Name Server:" or "nserver:"
+var nameServerRegex = regexp.MustCompile(`(?im)^\s*(?:name ?servers?|nserver)\s*:[ \t]*(\S+)`)
+
+// parseWhois extracts the expiry date, creation date, registrar and name servers from a WHOIS record
+func parseWhois(whoisInfo string) DomainInfo {
+	whoisInfo = unfoldWhois(whoisInfo)
+	info := DomainInfo{Expiry: extractExpiryDateFromWhois(whoisInfo)}
+	if matches := registrarRegex.FindStringSubmatch(whoisInfo); matches != nil {
+		info.Registrar = matches[1]
+	}
+	for _, matches := range createdRegex.FindAllStringSubmatch(whoisInfo, -1) {
+		if created := parseExpiryDate(matches[1]); !created.IsZero() {
+			info.Created = created
+			break
+		}
+	}
+	seen := make(map[string]bool)
+	for _, matches := range nameServerRegex.FindAllStringSubmatch(whoisInfo, -1) {
+		server := strings.ToLower(strings.TrimSuffix(matches[1], "."))
+		if !seen[server] {
+			seen[server] = true
+			info.NameServers = append(info.NameServers, server)
+		}
+	}
+	return info
+}
+
+// unfoldWhois rewrites the block fields of registries such as Nominet, whose values are on
+// the more indented lines below the key, into one "key: value" line per value:
+//
+//	Name servers:
+//	    ns1.example.com
+//
+// becomes "Name servers: ns1.example.com". Indented lines that are fields of their own,
+// as in "Relevant dates:" blocks, are kept as they are.
+func unfoldWhois(whoisInfo string) string {
+	lines := strings.Split(whoisInfo, "\n")
+	key, keyIndent := "", 0
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		switch {
+		case trimmed == "" || indent <= keyIndent:
+			key = ""
+		case key != "" && !strings.Contains(trimmed, ":"):
+			lines[i] = key + " " + trimmed
+			continue
+		}
+		if strings.HasSuffix(trimmed, ":") {
+			key, keyIndent = trimmed, indent
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// expiryRegex matches the expiry field of common registrars, e.g. "Registry Expiry Date:",
+// "Expiration Time:", "paid-till:", "expire:" or "[Expires on]"
+var expiryRegex = regexp.MustCompile(`(?im)^\s*\[?((?:registry |registrar registration )?(?:expiration|expiry|expires|expire)(?: date| time| on)?|paid-till|renewal date|valid until)\]?[\s.]*:?[ \t]*(\S.*?)\s*$`)
+
+// expiryLayouts lists the date formats registrars use for expiry dates
+var expiryLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05Z",
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006.01.02",
+	"2006/01/02",
+	"02-Jan-2006",
+	"02-January-2006",
+	"02.01.2006",
+	"2.1.2006",
+	"02/01/2006",
+	"January 2 2006",
+	"Jan 2 2006",
+	"Mon Jan 2 15:04:05 MST 2006",
+	"20060102",
+}
+
+// extractExpiryDateFromWhois extracts the expiry date from the WHOIS information
+func extractExpiryDateFromWhois(whoisInfo string) time.Time {
+	for _, matches := range expiryRegex.FindAllStringSubmatch(whoisInfo, -1) {
+		if expiryDate := parseExpiryDate(matches[2]); !expiryDate.IsZero() {
+			return expiryDate
+		}
+	}
+
+	return time.Time{} // return zero value if no expiry date is found
+}
+
+// parseExpiryDate tries each known layout against the value, then against its first field
+// to tolerate trailing annotations such as "2025-01-01 (YYYY-MM-DD)"
+func parseExpiryDate(value string) time.Time {
+	candidates := []string{value}
+	if fields := strings.Fields(value); len(fields) > 1 {
+		candidates = append(candidates, fields[0])
+	}
+
+	for _, candidate := range candidates {
+		for _, layout := range expiryLayouts {
+			if expiryDate, err := time.Parse(layout, candidate); err == nil {
+				return expiryDate
+			}
+		}
+	}
+	return time.Time{}
+}
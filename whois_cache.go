@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// whoisCacheEntry is the cached result of a WHOIS lookup; a zero Expiry means none was found
+type whoisCacheEntry struct {
+	DomainInfo
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// whoisCache persists parsed WHOIS details on disk, keyed by domain; it is safe for concurrent use
+type whoisCache struct {
+	mu      sync.Mutex
+	path    string
+	ttl     time.Duration
+	entries map[string]whoisCacheEntry
+}
+
+// defaultWhoisCachePath returns the cache file location under the user's cache directory
+func defaultWhoisCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gemails", "whois.json")
+}
+
+// loadWhoisCache reads the cache file at path; a missing or unreadable file yields an empty cache
+func loadWhoisCache(path string, ttl time.Duration) *whoisCache {
+	cache := &whoisCache{path: path, ttl: ttl, entries: make(map[string]whoisCacheEntry)}
+	if data, err := ioutil.ReadFile(path); err == nil {
+		json.Unmarshal(data, &cache.entries)
+	}
+	return cache
+}
+
+// get returns the cached details for domain if they were fetched within the TTL
+func (c *whoisCache) get(domain string) (DomainInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[domain]
+	if !ok || time.Since(entry.FetchedAt) > c.ttl {
+		return DomainInfo{}, false
+	}
+	return entry.DomainInfo, true
+}
+
+// put records the details for domain as fetched now
+func (c *whoisCache) put(domain string, info DomainInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[domain] = whoisCacheEntry{DomainInfo: info, FetchedAt: time.Now()}
+}
+
+// save writes the cache back to disk, creating its directory if needed
+func (c *whoisCache) save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, data, 0644)
+}
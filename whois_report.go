@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// whoisRecord is the JSON representation of a domain's expiry check
+type whoisRecord struct {
+	Domain     string  `json:"domain"`
+	ExpiryDate *string `json:"expiryDate"`
+	DaysLeft   *int    `json:"daysLeft"`
+	Status     string  `json:"status"`
+	Reason     string  `json:"reason,omitempty"`
+	// Registrar, Created and NameServers are included when found in the WHOIS record
+	Registrar   string   `json:"registrar,omitempty"`
+	Created     *string  `json:"created,omitempty"`
+	NameServers []string `json:"nameServers,omitempty"`
+}
+
+// record returns the JSON representation of result
+func (w *whoisChecker) record(result domainExpiry) whoisRecord {
+	record := whoisRecord{Domain: result.domain, Registrar: result.info.Registrar, NameServers: result.info.NameServers}
+	if !result.info.Created.IsZero() {
+		created := result.info.Created.Format("2006-01-02")
+		record.Created = &created
+	}
+	record.Status = w.status(result)
+	switch record.Status {
+	case "error":
+		record.Reason = result.err.Error()
+	case "available":
+		record.Reason = "domain is not registered"
+	case "unknown":
+		record.Reason = "no expiry date found in WHOIS response"
+	default:
+		date := result.info.Expiry.Format("2006-01-02")
+		days := result.daysLeft()
+		record.ExpiryDate, record.DaysLeft = &date, &days
+	}
+	return record
+}
+
+// saveReport writes the expiry results as a JSON array to path, in the order given
+func (w *whoisChecker) saveReport(expiries []domainExpiry, path string) error {
+	records := make([]whoisRecord, 0, len(expiries))
+	for _, result := range expiries {
+		records = append(records, w.record(result))
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useRDAPServer makes handler the RDAP server of .com, recording the requested domains
+func useRDAPServer(t *testing.T, handler http.HandlerFunc) *[]string {
+	t.Helper()
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, strings.TrimPrefix(r.URL.Path, "/domain/"))
+		mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	rdapServers.once.Do(func() {})
+	rdapServers.byTLD = map[string]string{"com": server.URL + "/"}
+	rdapServers.err = nil
+	return &requested
+}
+
+// rdapRecord is the RDAP response of a domain registered until 2030
+const rdapRecord = `{"events": [{"eventAction": "registration", "eventDate": "2001-02-03T00:00:00Z"},
+	{"eventAction": "expiration", "eventDate": "2030-02-03T00:00:00Z"}]}`
+
+// serveWhois answers WHOIS queries for the domains of responses on a local port and
+// returns its address; unknown domains get an empty answer
+func serveWhois(t *testing.T, responses map[string]string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			query, _ := bufio.NewReader(conn).ReadString('\n')
+			fmt.Fprint(conn, responses[strings.TrimSpace(query)])
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestLookupExpirySubdomain(t *testing.T) {
+	requested := useRDAPServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/domain/example.com" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, rdapRecord)
+	})
+
+	checker := &whoisChecker{expiryDays: 30}
+	result := checker.lookupExpiry("eng.example.com")
+	if result.domain != "eng.example.com" {
+		t.Errorf("domain = %q, want the input eng.example.com", result.domain)
+	}
+	if result.available || result.err != nil {
+		t.Errorf("lookupExpiry(eng.example.com) = available %v, err %v, want a registered domain", result.available, result.err)
+	}
+	if got := result.info.Expiry.Format("2006-01-02"); got != "2030-02-03" {
+		t.Errorf("expiry = %s, want that of example.com, 2030-02-03", got)
+	}
+	if len(*requested) != 1 || (*requested)[0] != "example.com" {
+		t.Errorf("RDAP lookups = %v, want [example.com]", *requested)
+	}
+}
+
+func TestLookupExpiryAvailable(t *testing.T) {
+	useRDAPServer(t, http.NotFound)
+
+	checker := &whoisChecker{expiryDays: 30}
+	if result := checker.lookupExpiry("unregistered-example.com"); !result.available {
+		t.Errorf("lookupExpiry(unregistered-example.com) = %+v, want available", result)
+	}
+}
+
+func TestLookupExpirySubdomainNeverAvailable(t *testing.T) {
+	useRDAPServer(t, http.NotFound)
+	whois := serveWhois(t, map[string]string{
+		"unregistered-example.com": "No match for \"UNREGISTERED-EXAMPLE.COM\".\n>>> Last update of whois database: 2024-09-01T12:00:00Z <<<\n",
+	})
+
+	checker := &whoisChecker{expiryDays: 30, servers: map[string]string{"com": whois}}
+	result := checker.lookupExpiry("eng.unregistered-example.com")
+	if result.available || result.err != nil {
+		t.Errorf("lookupExpiry(eng.unregistered-example.com) = available %v, err %v, want neither", result.available, result.err)
+	}
+	if status := checker.status(result); status != "unknown" {
+		t.Errorf("status = %q, want unknown", status)
+	}
+}
+
+func TestLookupExpiryRegistrarNoMatch(t *testing.T) {
+	// The registry has the domain, but the registrar it refers to has no record of it.
+	// The referral names localhost, as the library does not follow one to the same host.
+	registrar := strings.Replace(serveWhois(t, map[string]string{
+		"example.com": "No match for \"EXAMPLE.COM\".\n",
+	}), "127.0.0.1", "localhost", 1)
+	registry := serveWhois(t, map[string]string{
+		"example.com": "   Domain Name: EXAMPLE.COM\n" +
+			"   Registrar WHOIS Server: " + registrar + "\n" +
+			"   Creation Date: 1995-08-14T04:00:00Z\n" +
+			"   Registry Expiry Date: 2030-08-13T04:00:00Z\n" +
+			"   Registrar: Example Registrar, Inc.\n",
+	})
+
+	checker := &whoisChecker{expiryDays: 30, noRDAP: true, servers: map[string]string{"com": registry}}
+	result := checker.lookupExpiry("example.com")
+	if result.available || result.err != nil {
+		t.Fatalf("lookupExpiry(example.com) = available %v, err %v, want a registered domain", result.available, result.err)
+	}
+	if got := result.info.Expiry.Format("2006-01-02"); got != "2030-08-13" {
+		t.Errorf("expiry = %s, want 2030-08-13", got)
+	}
+}
+
+func TestRegistrableDomain(t *testing.T) {
+	tests := map[string]string{
+		"example.com":        "example.com",
+		"eng.example.com":    "example.com",
+		"cs.stanford.edu":    "stanford.edu",
+		"Mail.Example.co.uk": "example.co.uk",
+		"example.co.uk":      "example.co.uk",
+		"a.b.example.com.au": "example.com.au",
+		"localhost":          "",
+	}
+	for domain, want := range tests {
+		if got := registrableDomain(domain); got != want {
+			t.Errorf("registrableDomain(%q) = %q, want %q", domain, got, want)
+		}
+	}
+}
+
+// verisignRecord is the registry answer for a registered .com, followed by the registrar's
+// answer the referral leads to
+const verisignRecord = `   Domain Name: GOOGLE.COM
+   Registry Domain ID: 2138514_DOMAIN_COM-VRSN
+   Registrar WHOIS Server: whois.markmonitor.com
+   Registrar URL: http://www.markmonitor.com
+   Updated Date: 2019-09-09T15:39:04Z
+   Creation Date: 1997-09-15T04:00:00Z
+   Registry Expiry Date: 2028-09-14T04:00:00Z
+   Registrar: MarkMonitor Inc.
+   Registrar IANA ID: 292
+   Registrar Abuse Contact Email: abusecomplaints@markmonitor.com
+   Domain Status: clientDeleteProhibited https://icann.org/epp#clientDeleteProhibited
+   Name Server: NS1.GOOGLE.COM
+   Name Server: NS2.GOOGLE.COM
+   DNSSEC: unsigned
+>>> Last update of whois database: 2024-09-01T12:00:00Z <<<
+
+NOTICE: The expiration date displayed in this record is the date the
+registrar's sponsorship of the domain name registration in the registry is
+currently set to expire. This date does not necessarily reflect the expiration
+date of the domain name registrant's agreement with the sponsoring
+registrar.
+Domain Name: google.com
+Registry Domain ID: 2138514_DOMAIN_COM-VRSN
+Registrar WHOIS Server: whois.markmonitor.com
+Updated Date: 2019-09-09T15:39:04+0000
+Creation Date: 1997-09-15T07:00:00+0000
+Registrar Registration Expiration Date: 2028-09-13T07:00:00+0000
+Registrar: MarkMonitor, Inc.
+Name Server: ns1.google.com
+Name Server: ns2.google.com
+`
+
+// markMonitorRecord is a registrar answer on its own, with numeric zone offsets
+const markMonitorRecord = `Domain Name: google.com
+Updated Date: 2019-09-09T15:39:04+0000
+Creation Date: 1997-09-15T07:00:00+0000
+Registrar Registration Expiration Date: 2028-09-13T07:00:00+0000
+Registrar: MarkMonitor, Inc.
+Name Server: ns1.google.com
+`
+
+// denicRecord is DENIC's answer for a registered .de, which publishes no expiry date
+const denicRecord = `% Restricted rights.
+%
+% Terms and Conditions of Use
+%
+% The above data may only be used within the scope of technical or
+% administrative necessities of Internet operation or to remedy legal
+% problems.
+
+Domain: google.de
+Nserver: ns1.google.com
+Nserver: ns2.google.com
+Nserver: ns1.google.com
+Status: connect
+Changed: 2018-03-12T21:44:25+01:00
+`
+
+// nominetRecord is Nominet's answer for a registered .uk, whose values are on the lines
+// below their keys
+const nominetRecord = `
+    Domain name:
+        google.co.uk
+
+    Data validation:
+        Nominet was able to match the registrant's name and address against a 3rd party data source on 10-Dec-2012
+
+    Registrar:
+        Markmonitor Inc. t/a MarkMonitor Inc. [Tag = MARKMONITOR]
+        URL: http://www.markmonitor.com
+
+    Relevant dates:
+        Registered on: 14-Feb-1999
+        Expiry date:  14-Feb-2025
+        Last updated:  13-Jan-2024
+
+    Registration status:
+        Registered until expiry date.
+
+    Name servers:
+        ns1.google.com
+        ns2.google.com
+
+    WHOIS lookup made at 12:00:00 01-Sep-2024
+`
+
+func TestParseWhois(t *testing.T) {
+	tests := []struct {
+		name        string
+		record      string
+		expiry      string
+		created     string
+		registrar   string
+		nameServers []string
+	}{
+		{"verisign and registrar", verisignRecord, "2028-09-14", "1997-09-15", "MarkMonitor Inc.", []string{"ns1.google.com", "ns2.google.com"}},
+		{"registrar", markMonitorRecord, "2028-09-13", "1997-09-15", "MarkMonitor, Inc.", []string{"ns1.google.com"}},
+		{"denic", denicRecord, "", "", "", []string{"ns1.google.com", "ns2.google.com"}},
+		{"nominet", nominetRecord, "2025-02-14", "1999-02-14", "Markmonitor Inc. t/a MarkMonitor Inc. [Tag = MARKMONITOR]", []string{"ns1.google.com", "ns2.google.com"}},
+	}
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02")
+	}
+	for _, test := range tests {
+		info := parseWhois(test.record)
+		if got := date(info.Expiry); got != test.expiry {
+			t.Errorf("%s: expiry = %q, want %q", test.name, got, test.expiry)
+		}
+		if got := date(info.Created); got != test.created {
+			t.Errorf("%s: created = %q, want %q", test.name, got, test.created)
+		}
+		if info.Registrar != test.registrar {
+			t.Errorf("%s: registrar = %q, want %q", test.name, info.Registrar, test.registrar)
+		}
+		if !reflect.DeepEqual(info.NameServers, test.nameServers) {
+			t.Errorf("%s: name servers = %v, want %v", test.name, info.NameServers, test.nameServers)
+		}
+	}
+}
+
+func TestParseExpiryDate(t *testing.T) {
+	tests := map[string]string{
+		"2028-09-14T04:00:00Z":         "2028-09-14",
+		"2028-09-14T04:00:00.123Z":     "2028-09-14",
+		"2028-09-13T07:00:00+0000":     "2028-09-13",
+		"2028-09-13T07:00:00-07:00":    "2028-09-13",
+		"2028-09-14 04:00:00":          "2028-09-14",
+		"2028-09-14 (YYYY-MM-DD)":      "2028-09-14",
+		"2028.09.14":                   "2028-09-14",
+		"14-Feb-2025":                  "2025-02-14",
+		"14.02.2025":                   "2025-02-14",
+		"February 14 2025":             "2025-02-14",
+		"Fri Feb 14 00:00:00 GMT 2025": "2025-02-14",
+		"20250214":                     "2025-02-14",
+		"until expiry date.":           "",
+		"":                             "",
+	}
+	for value, want := range tests {
+		got := parseExpiryDate(value)
+		if got.IsZero() && want == "" {
+			continue
+		}
+		if got.Format("2006-01-02") != want {
+			t.Errorf("parseExpiryDate(%q) = %v, want %s", value, got, want)
+		}
+	}
+}
+
+func TestWhoisNotFound(t *testing.T) {
+	tests := []struct {
+		name   string
+		record string
+		want   bool
+	}{
+		{"verisign no match", "No match for \"UNREGISTERED-EXAMPLE.COM\".\n>>> Last update of whois database: 2024-09-01T12:00:00Z <<<\n", true},
+		{"denic free", "Domain: unregistered-example.de\nStatus: free\n", true},
+		{"nominet no match", "\n    No match for \"unregistered-example.co.uk\".\n\n    This domain name has not been registered.\n", true},
+		{"registrar not found", "Domain not found.\n", true},
+		{"registry record, registrar no match", verisignRecord + "No match for \"GOOGLE.COM\".\n", false},
+		{"registered", verisignRecord, false},
+		{"denic registered", denicRecord, false},
+		{"nominet registered", nominetRecord, false},
+	}
+	for _, test := range tests {
+		if got := whoisNotFound(test.record, parseWhois(test.record)); got != test.want {
+			t.Errorf("%s: whoisNotFound = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// webhookClient posts -webhook notifications
+var webhookClient = &http.Client{Timeout: 15 * time.Second}
+
+// webhookPayload is the JSON posted to -webhook. Slack incoming webhooks display text and
+// ignore the other fields, which carry the details for generic receivers.
+type webhookPayload struct {
+	Text       string        `json:"text"`
+	ExpiryDays int           `json:"expiryDays"`
+	Domains    []whoisRecord `json:"domains"`
+}
+
+// validateWebhook exits unless webhookURL, the -webhook flag, is empty or an HTTP(S) URL
+func validateWebhook(webhookURL string) {
+	if webhookURL == "" {
+		return
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fatalf("Invalid -webhook URL %q (expected an http or https URL)", webhookURL)
+	}
+}
+
+// notifyExpiring posts the domains of expiries within the -expiry-days threshold to
+// webhookURL; nothing is sent when none are
+func (w *whoisChecker) notifyExpiring(expiries []domainExpiry, webhookURL string) error {
+	payload := webhookPayload{ExpiryDays: w.expiryDays}
+	var lines []string
+	for _, result := range expiries {
+		if w.status(result) != "expiring" {
+			continue
+		}
+		record := w.record(result)
+		payload.Domains = append(payload.Domains, record)
+		lines = append(lines, fmt.Sprintf("• %s expires on %s (%d days left)", record.Domain, *record.ExpiryDate, *record.DaysLeft))
+	}
+	if len(payload.Domains) == 0 {
+		return nil
+	}
+	noun := "domains expire"
+	if len(payload.Domains) == 1 {
+		noun = "domain expires"
+	}
+	payload.Text = fmt.Sprintf("gemails: %d %s within %d days\n%s", len(payload.Domains), noun, w.expiryDays, strings.Join(lines, "\n"))
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(webhookURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responded with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	infof("Posted the expiring domains to the webhook")
+	return nil
+}